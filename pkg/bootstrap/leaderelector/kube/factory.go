@@ -5,6 +5,7 @@ import (
 	"os"
 	"time"
 
+	"github.com/rotisserie/eris"
 	"github.com/solo-io/gloo/pkg/bootstrap/leaderelector"
 	"github.com/solo-io/go-utils/contextutils"
 	"k8s.io/client-go/rest"
@@ -14,6 +15,15 @@ import (
 
 var _ leaderelector.ElectionFactory = new(kubeElectionFactory)
 
+// Delay before a newly elected leader starts leading, so that it does not act before the previous leader has stopped
+const gracePeriodEnvVar = "LEADER_ELECTION_GRACE_PERIOD"
+
+var (
+	InvalidGracePeriodError = func(err error) error {
+		return eris.Wrapf(err, "invalid %s", gracePeriodEnvVar)
+	}
+)
+
 // kubeElectionFactory is the implementation for coordinating leader election using
 // the k8s leader election tool: https://github.com/kubernetes/client-go/tree/master/tools/leaderelection
 type kubeElectionFactory struct {
@@ -30,6 +40,12 @@ func (f *kubeElectionFactory) StartElection(ctx context.Context, config *leadere
 	elected := make(chan struct{})
 	identity := leaderelector.NewIdentity(elected)
 
+	leaseDuration := getLeaseDuration()
+	gracePeriod, err := getGracePeriod(leaseDuration)
+	if err != nil {
+		return identity, err
+	}
+
 	leOpts := leaderelection.Options{
 		LeaderElection:          true,
 		LeaderElectionID:        config.Id,
@@ -48,15 +64,15 @@ func (f *kubeElectionFactory) StartElection(ctx context.Context, config *leadere
 			Lock: resourceLock,
 			// Define the following values according to the defaults:
 			// https://github.com/kubernetes/client-go/blob/master/tools/leaderelection/leaderelection.go
-			LeaseDuration: getLeaseDuration(),
+			LeaseDuration: leaseDuration,
 			RenewDeadline: 10 * time.Second,
 			RetryPeriod:   2 * time.Second,
 			Callbacks: k8sleaderelection.LeaderCallbacks{
-				OnStartedLeading: func(callbackCtx context.Context) {
+				OnStartedLeading: WithGracePeriod(gracePeriod, func(callbackCtx context.Context) {
 					contextutils.LoggerFrom(callbackCtx).Debug("Started Leading")
 					close(elected)
 					config.OnStartedLeading(callbackCtx)
-				},
+				}),
 				OnStoppedLeading: func() {
 					contextutils.LoggerFrom(ctx).Error("Stopped Leading")
 					config.OnStoppedLeading()
@@ -86,10 +102,46 @@ func getLeaseDuration() time.Duration {
 
 	leaseDurationStr := os.Getenv("LEADER_ELECTION_LEASE_DURATION")
 	if leaseDurationStr != "" {
-		if dur, err := time.ParseDuration(leaseDurationStr); err != nil {
+		if dur, err := time.ParseDuration(leaseDurationStr); err == nil {
 			leaseDuration = dur
 		}
 	}
 
 	return leaseDuration
 }
+
+func getGracePeriod(leaseDuration time.Duration) (time.Duration, error) {
+	gracePeriodStr := os.Getenv(gracePeriodEnvVar)
+	if gracePeriodStr == "" {
+		return 0, nil
+	}
+	gracePeriod, err := time.ParseDuration(gracePeriodStr)
+	if err != nil {
+		return 0, InvalidGracePeriodError(err)
+	}
+	if gracePeriod < 0 {
+		return 0, InvalidGracePeriodError(eris.Errorf("grace period %s must not be negative", gracePeriod))
+	}
+	// the previous leader stops acting at the latest when its lease expires, waiting any longer is unnecessary
+	if gracePeriod >= leaseDuration {
+		return 0, InvalidGracePeriodError(eris.Errorf("grace period %s must be shorter than the lease duration %s", gracePeriod, leaseDuration))
+	}
+	return gracePeriod, nil
+}
+
+// WithGracePeriod delays the started leading callback by the grace period.
+// The callback is not invoked if leadership is lost before the grace period has elapsed.
+func WithGracePeriod(gracePeriod time.Duration, onStartedLeading func(ctx context.Context)) func(ctx context.Context) {
+	if gracePeriod <= 0 {
+		return onStartedLeading
+	}
+	return func(ctx context.Context) {
+		contextutils.LoggerFrom(ctx).Debugf("Waiting %s before leading", gracePeriod)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(gracePeriod):
+			onStartedLeading(ctx)
+		}
+	}
+}
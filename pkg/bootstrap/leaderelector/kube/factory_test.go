@@ -0,0 +1,121 @@
+package kube_test
+
+import (
+	"context"
+	"os"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"github.com/solo-io/gloo/pkg/bootstrap/leaderelector"
+	"github.com/solo-io/gloo/pkg/bootstrap/leaderelector/kube"
+)
+
+var _ = Describe("Kube Election Factory", func() {
+
+	Context("grace period", func() {
+
+		var (
+			ctx    context.Context
+			cancel context.CancelFunc
+		)
+
+		BeforeEach(func() {
+			ctx, cancel = context.WithCancel(context.Background())
+		})
+
+		AfterEach(func() {
+			cancel()
+			os.Unsetenv("LEADER_ELECTION_GRACE_PERIOD")
+		})
+
+		It("waits for the grace period before the started leading callback fires", func() {
+			startedLeading := make(chan time.Time, 1)
+			callback := kube.WithGracePeriod(200*time.Millisecond, func(ctx context.Context) {
+				startedLeading <- time.Now()
+			})
+
+			elected := time.Now()
+			go callback(ctx)
+
+			Consistently(startedLeading, 100*time.Millisecond).ShouldNot(Receive())
+			var started time.Time
+			Eventually(startedLeading, time.Second).Should(Receive(&started))
+			Expect(started.Sub(elected)).To(BeNumerically(">=", 200*time.Millisecond))
+		})
+
+		It("does not fire the started leading callback if leadership is lost during the grace period", func() {
+			startedLeading := make(chan struct{}, 1)
+			callback := kube.WithGracePeriod(200*time.Millisecond, func(ctx context.Context) {
+				startedLeading <- struct{}{}
+			})
+
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				callback(ctx)
+			}()
+			cancel()
+
+			Eventually(done, time.Second).Should(BeClosed())
+			Expect(startedLeading).NotTo(Receive())
+		})
+
+		It("fires the started leading callback immediately without a grace period", func() {
+			startedLeading := make(chan struct{}, 1)
+			kube.WithGracePeriod(0, func(ctx context.Context) {
+				startedLeading <- struct{}{}
+			})(ctx)
+
+			Expect(startedLeading).To(Receive())
+		})
+
+		DescribeTable("rejects invalid grace periods",
+			func(gracePeriod string, expectedErr string) {
+				os.Setenv("LEADER_ELECTION_GRACE_PERIOD", gracePeriod)
+
+				_, err := kube.NewElectionFactory(nil).StartElection(ctx, &leaderelector.ElectionConfig{})
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring(expectedErr))
+			},
+			Entry("not a duration", "soon", "invalid LEADER_ELECTION_GRACE_PERIOD"),
+			Entry("negative", "-1s", "must not be negative"),
+			Entry("not shorter than the lease", "1m", "must be shorter than the lease duration"),
+		)
+	})
+
+	Context("lease duration", func() {
+
+		var (
+			ctx    context.Context
+			cancel context.CancelFunc
+		)
+
+		BeforeEach(func() {
+			ctx, cancel = context.WithCancel(context.Background())
+		})
+
+		AfterEach(func() {
+			cancel()
+			os.Unsetenv("LEADER_ELECTION_GRACE_PERIOD")
+			os.Unsetenv("LEADER_ELECTION_LEASE_DURATION")
+		})
+
+		// the grace period must be shorter than the lease, which makes the lease duration in use observable
+		DescribeTable("uses the configured lease duration",
+			func(leaseDuration, gracePeriod string, expectedErr string) {
+				os.Setenv("LEADER_ELECTION_LEASE_DURATION", leaseDuration)
+				os.Setenv("LEADER_ELECTION_GRACE_PERIOD", gracePeriod)
+
+				// without an id the election itself fails once the grace period has been accepted
+				_, err := kube.NewElectionFactory(nil).StartElection(ctx, &leaderelector.ElectionConfig{})
+				Expect(err).To(MatchError(ContainSubstring(expectedErr)))
+			},
+			Entry("a custom lease shorter than the grace period", "5s", "10s", "must be shorter than the lease duration 5s"),
+			Entry("a custom lease longer than the default", "2m", "1m", "LeaderElectionID must be configured"),
+			Entry("the default lease when unset", "", "10s", "LeaderElectionID must be configured"),
+			Entry("the default lease when invalid", "soon", "20s", "must be shorter than the lease duration 15s"),
+		)
+	})
+})
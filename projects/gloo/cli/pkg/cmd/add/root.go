@@ -32,6 +32,7 @@ func RootCmd(opts *options.Options, optionsFunc ...cliutils.OptionsFunc) *cobra.
 	}
 	pflags := cmd.PersistentFlags()
 	flagutils.AddOutputFlag(pflags, &opts.Top.Output)
+	flagutils.RegisterOutputFlagCompletion(cmd, flagutils.ResourceOutputTypes)
 	flagutils.AddMetadataFlags(pflags, &opts.Metadata)
 	flagutils.AddDryRunFlag(pflags, &opts.Add.DryRun)
 
@@ -44,6 +44,12 @@ func Route(opts *options.Options, optionsFunc ...cliutils.OptionsFunc) *cobra.Co
 			"" +
 			"Usage: `glooctl add route [--name virtual-service-name] [--namespace namespace] [--index x] ...`",
 		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := flagutils.ValidateFlagRules(cmd.Flags(),
+				flagutils.MutuallyExclusive("path-exact", "path-regex", "path-prefix"),
+				flagutils.MutuallyExclusive("dest-name", "upstream-group-name", "delegate-name"),
+			); err != nil {
+				return err
+			}
 			if opts.Top.Interactive {
 				if err := surveyutils.AddRouteFlagsInteractive(opts); err != nil {
 					return err
@@ -58,6 +64,7 @@ func Route(opts *options.Options, optionsFunc ...cliutils.OptionsFunc) *cobra.Co
 	}
 	pflags := cmd.PersistentFlags()
 	flagutils.AddOutputFlag(pflags, &opts.Top.Output)
+	flagutils.RegisterOutputFlagCompletion(cmd, flagutils.ResourceOutputTypes)
 	flagutils.AddRouteFlags(pflags, &opts.Add.Route)
 	cliutils.ApplyOptions(cmd, optionsFunc)
 	return cmd
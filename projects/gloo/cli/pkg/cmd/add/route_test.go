@@ -137,4 +137,19 @@ var _ = Describe("Routes", func() {
 			},
 		}))
 	})
+
+	It("should reject more than one path matcher", func() {
+		err := testutils.Glooctl("add route --path-exact /a --path-prefix /b --dest-name default-petstore-8080")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("flags --path-exact, --path-prefix cannot be used together"))
+
+		_, err = helpers.MustVirtualServiceClient(ctx).Read("gloo-system", "default", clients.ReadOpts{})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should reject more than one destination", func() {
+		err := testutils.Glooctl("add route --path-prefix /a --dest-name default-petstore-8080 --delegate-name my-delegate")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("flags --dest-name, --delegate-name cannot be used together"))
+	})
 })
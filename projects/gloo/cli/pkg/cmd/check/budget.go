@@ -0,0 +1,45 @@
+package check
+
+import (
+	"fmt"
+
+	"github.com/solo-io/gloo/projects/gloo/cli/pkg/cmd/options"
+)
+
+// resourceBudget caps how many resources a single check examines, as set with --max-resources.
+// A check whose budget runs out reports that its results are partial.
+type resourceBudget struct {
+	max      int
+	examined int
+	partial  bool
+}
+
+func newResourceBudget(opts *options.Options) *resourceBudget {
+	return &resourceBudget{max: opts.Check.MaxResources}
+}
+
+// take returns true if one more resource can be examined
+func (b *resourceBudget) take() bool {
+	return b.limit(1) == 1
+}
+
+// limit returns how many of the next n resources can be examined
+func (b *resourceBudget) limit(n int) int {
+	if b.max <= 0 {
+		return n
+	}
+	if remaining := b.max - b.examined; n > remaining {
+		n = remaining
+		b.partial = true
+	}
+	b.examined += n
+	return n
+}
+
+// appendStatus appends the status of the check, followed by a warning if the check did not examine every resource
+func (b *resourceBudget) appendStatus(name string, status string) {
+	printer.AppendStatus(name, status)
+	if b.partial {
+		printer.AppendMessage(fmt.Sprintf("Warning: only the first %d %s were checked (--max-resources), results are partial", b.max, name))
+	}
+}
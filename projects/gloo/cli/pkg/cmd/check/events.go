@@ -0,0 +1,159 @@
+package check
+
+import (
+	"context"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/solo-io/gloo/projects/gloo/cli/pkg/helpers"
+	"github.com/solo-io/k8s-utils/kubeutils"
+	"github.com/solo-io/solo-kit/pkg/api/v1/resources/core"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/metadata"
+	"k8s.io/client-go/tools/record"
+)
+
+const (
+	eventSourceComponent = "glooctl-check"
+	// reason of the events emitted on resources which fail a check
+	CheckFailedReason = "CheckFailed"
+	// how long glooctl waits for the recorded events to be written before it exits
+	eventFlushTimeout = 5 * time.Second
+)
+
+var (
+	// EventRecorderFactory creates the recorder of the events emitted with --emit-events, along with a function
+	// stopping it once the checks are done. It can be replaced, e.g. for testing.
+	EventRecorderFactory = newKubeEventRecorder
+
+	// records the events of the current check run, nil unless --emit-events is set
+	eventRecorder record.EventRecorder
+
+	deploymentGvk = appsv1.SchemeGroupVersion.WithKind("Deployment")
+	podGvk        = corev1.SchemeGroupVersion.WithKind("Pod")
+)
+
+type checkedResource interface {
+	GetMetadata() *core.Metadata
+	GroupVersionKind() schema.GroupVersionKind
+}
+
+// events are written asynchronously, so the returned stop function waits for the recorded events to be written
+// before shutting down, as glooctl exits right after
+func newKubeEventRecorder() (record.EventRecorder, func()) {
+	broadcaster := record.NewBroadcaster()
+	sink := &typedcorev1.EventSinkImpl{Interface: helpers.MustKubeClient().CoreV1().Events("")}
+	pending := &sync.WaitGroup{}
+	broadcaster.StartEventWatcher(func(event *corev1.Event) {
+		defer pending.Done()
+		// a failure to write an event must not fail the checks
+		_, _ = sink.Create(event)
+	})
+	recorder := &pendingEventRecorder{
+		EventRecorder: broadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: eventSourceComponent}),
+		pending:       pending,
+		uids:          newKubeUidResolver(),
+	}
+	return recorder, func() {
+		written := make(chan struct{})
+		go func() {
+			pending.Wait()
+			close(written)
+		}()
+		// events dropped by the broadcaster are never written, so do not wait for them forever
+		select {
+		case <-written:
+		case <-time.After(eventFlushTimeout):
+		}
+		broadcaster.Shutdown()
+	}
+}
+
+// the metadata of the gloo resources does not carry their kubernetes UID, which `kubectl describe` needs to find
+// the events of a resource, so it is looked up when the resources are stored as CRDs
+func newKubeUidResolver() metadata.Interface {
+	cfg, err := kubeutils.GetConfig("", os.Getenv("KUBECONFIG"))
+	if err != nil {
+		return nil
+	}
+	client, err := metadata.NewForConfig(cfg)
+	if err != nil {
+		return nil
+	}
+	return client
+}
+
+// pendingEventRecorder keeps track of the events which are recorded but not written yet
+type pendingEventRecorder struct {
+	record.EventRecorder
+	pending *sync.WaitGroup
+	// resolves the UID of the resources recorded without one, nil if it cannot be resolved
+	uids metadata.Interface
+}
+
+func (r *pendingEventRecorder) Event(object runtime.Object, eventtype, reason, message string) {
+	r.pending.Add(1)
+	r.resolveUid(object)
+	r.EventRecorder.Event(object, eventtype, reason, message)
+}
+
+// the gloo resources are not necessarily stored in kubernetes, e.g. with consul, in which case the UID stays empty
+func (r *pendingEventRecorder) resolveUid(object runtime.Object) {
+	ref, ok := object.(*corev1.ObjectReference)
+	if !ok || ref.UID != "" || r.uids == nil {
+		return
+	}
+	gvr, _ := meta.UnsafeGuessKindToResource(ref.GroupVersionKind())
+	resource, err := r.uids.Resource(gvr).Namespace(ref.Namespace).Get(context.Background(), ref.Name, metav1.GetOptions{})
+	if err != nil {
+		return
+	}
+	ref.UID = resource.GetUID()
+}
+
+func (r *pendingEventRecorder) Eventf(object runtime.Object, eventtype, reason, messageFmt string, args ...interface{}) {
+	r.pending.Add(1)
+	r.resolveUid(object)
+	r.EventRecorder.Eventf(object, eventtype, reason, messageFmt, args...)
+}
+
+func (r *pendingEventRecorder) AnnotatedEventf(object runtime.Object, annotations map[string]string, eventtype, reason, messageFmt string, args ...interface{}) {
+	r.pending.Add(1)
+	r.resolveUid(object)
+	r.EventRecorder.AnnotatedEventf(object, annotations, eventtype, reason, messageFmt, args...)
+}
+
+// recordCheckFailure emits a warning event on a resource which failed a check, so that the failure is visible
+// in the cluster, e.g. with `kubectl describe`
+func recordCheckFailure(resource checkedResource, message string) {
+	recordFailure(resource.GroupVersionKind(), resource.GetMetadata().GetNamespace(), resource.GetMetadata().GetName(), "", message)
+}
+
+// recordKubeCheckFailure is recordCheckFailure for the kubernetes resources gloo runs as, e.g. deployments and pods
+func recordKubeCheckFailure(gvk schema.GroupVersionKind, resource metav1.Object, message string) {
+	recordFailure(gvk, resource.GetNamespace(), resource.GetName(), resource.GetUID(), message)
+}
+
+func recordFailure(gvk schema.GroupVersionKind, namespace, name string, uid types.UID, message string) {
+	if eventRecorder == nil {
+		return
+	}
+	ref := &corev1.ObjectReference{
+		Kind:       gvk.Kind,
+		APIVersion: gvk.GroupVersion().String(),
+		Namespace:  namespace,
+		Name:       name,
+		UID:        uid,
+	}
+	eventRecorder.Event(ref, corev1.EventTypeWarning, CheckFailedReason, strings.TrimSpace(message))
+}
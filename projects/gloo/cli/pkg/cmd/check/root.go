@@ -61,15 +61,26 @@ func RootCmd(opts *options.Options, optionsFunc ...cliutils.OptionsFunc) *cobra.
 			if !opts.Top.Output.IsTable() && !opts.Top.Output.IsJSON() {
 				return errors.New("Invalid output type. Only table (default) and json are supported.")
 			}
+			if opts.Check.MaxResources < 0 {
+				return errors.New("Invalid max resources. Must be 0 (no limit) or more.")
+			}
 
 			printer = printers.P{OutputType: opts.Top.Output}
 			printer.CheckResult = printer.NewCheckResult()
+			if opts.Check.EmitEvents {
+				var stopRecording func()
+				eventRecorder, stopRecording = EventRecorderFactory()
+				defer func() {
+					stopRecording()
+					eventRecorder = nil
+				}()
+			}
 			err := CheckResources(opts)
 
 			if err != nil {
 				// Not returning error here because this shouldn't propagate as a standard CLI error, which prints usage.
 				if opts.Top.Output.IsTable() {
-					return err
+					return errors.New(printers.RedactSecrets(err.Error()))
 				}
 			} else {
 				printer.AppendMessage("No problems detected.")
@@ -86,10 +97,14 @@ func RootCmd(opts *options.Options, optionsFunc ...cliutils.OptionsFunc) *cobra.
 	}
 	pflags := cmd.PersistentFlags()
 	flagutils.AddCheckOutputFlag(pflags, &opts.Top.Output)
+	flagutils.RegisterOutputFlagCompletion(cmd, flagutils.CheckOutputTypes)
 	flagutils.AddNamespaceFlag(pflags, &opts.Metadata.Namespace)
 	flagutils.AddPodSelectorFlag(pflags, &opts.Top.PodSelector)
 	flagutils.AddResourceNamespaceFlag(pflags, &opts.Top.ResourceNamespaces)
 	flagutils.AddExcludeCheckFlag(pflags, &opts.Top.CheckName)
+	flagutils.AddIncludeCheckFlag(pflags, &opts.Check.IncludeChecks)
+	flagutils.AddEmitEventsFlag(pflags, &opts.Check.EmitEvents)
+	flagutils.AddMaxResourcesFlag(pflags, &opts.Check.MaxResources)
 	cliutils.ApplyOptions(cmd, optionsFunc)
 	return cmd
 }
@@ -154,6 +169,21 @@ func CheckResources(opts *options.Options) error {
 		}
 	}
 
+	// advisory only, this check never fails glooctl check
+	if included := cliutils.Contains(opts.Check.IncludeChecks, "tunneling-connect-timeout"); included {
+		err := checkTunnelingConnectTimeouts(opts, namespaces, settings)
+		if err != nil {
+			multiErr = multierror.Append(multiErr, err)
+		}
+	}
+
+	if included := doesNotContain(opts.Top.CheckName, "tunneling-pipe-paths"); included {
+		err := checkTunnelingPipePaths(opts, namespaces, settings)
+		if err != nil {
+			multiErr = multierror.Append(multiErr, err)
+		}
+	}
+
 	if included := doesNotContain(opts.Top.CheckName, "upstreamgroup"); included {
 		err := checkUpstreamGroups(opts, namespaces)
 		if err != nil {
@@ -233,6 +263,7 @@ func CheckResources(opts *options.Options) error {
 
 func getAndCheckDeployments(opts *options.Options) (*appsv1.DeploymentList, error) {
 	printer.AppendCheck("Checking deployments... ")
+	budget := newResourceBudget(opts)
 	client, err := helpers.KubeClient()
 	if err != nil {
 		errMessage := "error getting KubeClient"
@@ -263,6 +294,9 @@ func getAndCheckDeployments(opts *options.Options) (*appsv1.DeploymentList, erro
 	}
 
 	for _, deployment := range deployments.Items {
+		if !budget.take() {
+			break
+		}
 		// possible condition types listed at https://godoc.org/k8s.io/api/apps/v1#DeploymentConditionType
 		// check for each condition independently because multiple conditions will be True and DeploymentReplicaFailure
 		// tends to provide the most explicit error message.
@@ -270,6 +304,7 @@ func getAndCheckDeployments(opts *options.Options) (*appsv1.DeploymentList, erro
 			setMessage(condition)
 			if condition.Type == appsv1.DeploymentReplicaFailure && condition.Status == corev1.ConditionTrue {
 				err := fmt.Errorf("Deployment %s in namespace %s failed to create pods!%s", deployment.Name, deployment.Namespace, message)
+				recordKubeCheckFailure(deploymentGvk, &deployment, err.Error())
 				multiErr = multierror.Append(multiErr, err)
 			}
 		}
@@ -278,6 +313,7 @@ func getAndCheckDeployments(opts *options.Options) (*appsv1.DeploymentList, erro
 			setMessage(condition)
 			if condition.Type == appsv1.DeploymentProgressing && condition.Status != corev1.ConditionTrue {
 				err := fmt.Errorf("Deployment %s in namespace %s is not progressing!%s", deployment.Name, deployment.Namespace, message)
+				recordKubeCheckFailure(deploymentGvk, &deployment, err.Error())
 				multiErr = multierror.Append(multiErr, err)
 			}
 		}
@@ -286,6 +322,7 @@ func getAndCheckDeployments(opts *options.Options) (*appsv1.DeploymentList, erro
 			setMessage(condition)
 			if condition.Type == appsv1.DeploymentAvailable && condition.Status != corev1.ConditionTrue {
 				err := fmt.Errorf("Deployment %s in namespace %s is not available!%s", deployment.Name, deployment.Namespace, message)
+				recordKubeCheckFailure(deploymentGvk, &deployment, err.Error())
 				multiErr = multierror.Append(multiErr, err)
 			}
 
@@ -301,15 +338,16 @@ func getAndCheckDeployments(opts *options.Options) (*appsv1.DeploymentList, erro
 		}
 	}
 	if multiErr != nil {
-		printer.AppendStatus("deployments", fmt.Sprintf("%v Errors!", multiErr.Len()))
+		budget.appendStatus("deployments", fmt.Sprintf("%v Errors!", multiErr.Len()))
 		return nil, multiErr
 	}
-	printer.AppendStatus("deployments", "OK")
+	budget.appendStatus("deployments", "OK")
 	return deployments, nil
 }
 
 func checkPods(opts *options.Options) error {
 	printer.AppendCheck("Checking pods... ")
+	budget := newResourceBudget(opts)
 	client, err := helpers.KubeClient()
 	if err != nil {
 		return err
@@ -322,6 +360,9 @@ func checkPods(opts *options.Options) error {
 	}
 	var multiErr *multierror.Error
 	for _, pod := range pods.Items {
+		if !budget.take() {
+			break
+		}
 		for _, condition := range pod.Status.Conditions {
 			var errorToPrint string
 			var message string
@@ -360,18 +401,19 @@ func checkPods(opts *options.Options) error {
 			}
 
 			if errorToPrint != "" {
+				recordKubeCheckFailure(podGvk, &pod, errorToPrint)
 				multiErr = multierror.Append(multiErr, fmt.Errorf(errorToPrint))
 			}
 		}
 	}
 	if multiErr != nil {
-		printer.AppendStatus("pods", fmt.Sprintf("%v Errors!", multiErr.Len()))
+		budget.appendStatus("pods", fmt.Sprintf("%v Errors!", multiErr.Len()))
 		return multiErr
 	}
 	if len(pods.Items) == 0 {
 		printer.AppendMessage("Warning: The provided label selector (" + opts.Top.PodSelector + ") applies to no pods")
 	} else {
-		printer.AppendStatus("pods", "OK")
+		budget.appendStatus("pods", "OK")
 	}
 	return nil
 }
@@ -394,6 +436,7 @@ func getNamespaces(ctx context.Context, settings *v1.Settings) ([]string, error)
 
 func checkUpstreams(opts *options.Options, namespaces []string) ([]string, error) {
 	printer.AppendCheck("Checking upstreams... ")
+	budget := newResourceBudget(opts)
 	var knownUpstreams []string
 	var multiErr *multierror.Error
 	for _, ns := range namespaces {
@@ -408,6 +451,11 @@ func checkUpstreams(opts *options.Options, namespaces []string) ([]string, error
 			continue
 		}
 		for _, upstream := range upstreams {
+			if !budget.take() {
+				// resources which are not examined can still be referenced
+				knownUpstreams = append(knownUpstreams, renderMetadata(upstream.GetMetadata()))
+				continue
+			}
 			if upstream.GetNamespacedStatuses() != nil {
 				namespacedStatuses := upstream.GetNamespacedStatuses()
 				for reporter, status := range namespacedStatuses.GetStatuses() {
@@ -416,10 +464,12 @@ func checkUpstreams(opts *options.Options, namespaces []string) ([]string, error
 						errMessage := fmt.Sprintf("Found rejected upstream by '%s': %s ", reporter, renderMetadata(upstream.GetMetadata()))
 						errMessage += fmt.Sprintf("(Reason: %s)", status.GetReason())
 						multiErr = multierror.Append(multiErr, errors.New(errMessage))
+						recordCheckFailure(upstream, errMessage)
 					case core.Status_Warning:
 						errMessage := fmt.Sprintf("Found upstream with warnings by '%s': %s ", reporter, renderMetadata(upstream.GetMetadata()))
 						errMessage += fmt.Sprintf("(Reason: %s)", status.GetReason())
 						multiErr = multierror.Append(multiErr, errors.New(errMessage))
+						recordCheckFailure(upstream, errMessage)
 					}
 				}
 				knownUpstreams = append(knownUpstreams, renderMetadata(upstream.GetMetadata()))
@@ -427,15 +477,16 @@ func checkUpstreams(opts *options.Options, namespaces []string) ([]string, error
 		}
 	}
 	if multiErr != nil {
-		printer.AppendStatus("upstreams", fmt.Sprintf("%v Errors!", multiErr.Len()))
+		budget.appendStatus("upstreams", fmt.Sprintf("%v Errors!", multiErr.Len()))
 		return knownUpstreams, multiErr
 	}
-	printer.AppendStatus("upstreams", "OK")
+	budget.appendStatus("upstreams", "OK")
 	return knownUpstreams, nil
 }
 
 func checkUpstreamGroups(opts *options.Options, namespaces []string) error {
 	printer.AppendCheck("Checking upstream groups... ")
+	budget := newResourceBudget(opts)
 	var multiErr *multierror.Error
 	for _, ns := range namespaces {
 		upstreamGroupClient, err := helpers.UpstreamGroupClient(opts.Top.Ctx, []string{ns})
@@ -452,6 +503,9 @@ func checkUpstreamGroups(opts *options.Options, namespaces []string) error {
 			return err
 		}
 		for _, upstreamGroup := range upstreamGroups {
+			if !budget.take() {
+				break
+			}
 			if upstreamGroup.GetNamespacedStatuses() != nil {
 				namespacedStatuses := upstreamGroup.GetNamespacedStatuses()
 				for reporter, status := range namespacedStatuses.GetStatuses() {
@@ -460,25 +514,28 @@ func checkUpstreamGroups(opts *options.Options, namespaces []string) error {
 						errMessage := fmt.Sprintf("Found rejected upstream group by '%s': %s ", reporter, renderMetadata(upstreamGroup.GetMetadata()))
 						errMessage += fmt.Sprintf("(Reason: %s)", status.GetReason())
 						multiErr = multierror.Append(multiErr, errors.New(errMessage))
+						recordCheckFailure(upstreamGroup, errMessage)
 					case core.Status_Warning:
 						errMessage := fmt.Sprintf("Found upstream group with warnings by '%s': %s ", reporter, renderMetadata(upstreamGroup.GetMetadata()))
 						errMessage += fmt.Sprintf("(Reason: %s)", status.GetReason())
 						multiErr = multierror.Append(multiErr, errors.New(errMessage))
+						recordCheckFailure(upstreamGroup, errMessage)
 					}
 				}
 			}
 		}
 	}
 	if multiErr != nil {
-		printer.AppendStatus("upstream groups", fmt.Sprintf("%v Errors!", multiErr.Len()))
+		budget.appendStatus("upstream groups", fmt.Sprintf("%v Errors!", multiErr.Len()))
 		return multiErr
 	}
-	printer.AppendStatus("upstream groups", "OK")
+	budget.appendStatus("upstream groups", "OK")
 	return nil
 }
 
 func checkAuthConfigs(opts *options.Options, namespaces []string) ([]string, error) {
 	printer.AppendCheck("Checking auth configs... ")
+	budget := newResourceBudget(opts)
 	var knownAuthConfigs []string
 	var multiErr *multierror.Error
 	for _, ns := range namespaces {
@@ -493,6 +550,11 @@ func checkAuthConfigs(opts *options.Options, namespaces []string) ([]string, err
 			continue
 		}
 		for _, authConfig := range authConfigs {
+			if !budget.take() {
+				// resources which are not examined can still be referenced
+				knownAuthConfigs = append(knownAuthConfigs, renderMetadata(authConfig.GetMetadata()))
+				continue
+			}
 			if authConfig.GetNamespacedStatuses() != nil {
 				namespacedStatuses := authConfig.GetNamespacedStatuses()
 				for reporter, status := range namespacedStatuses.GetStatuses() {
@@ -501,10 +563,12 @@ func checkAuthConfigs(opts *options.Options, namespaces []string) ([]string, err
 						errMessage := fmt.Sprintf("Found rejected auth config by '%s': %s ", reporter, renderMetadata(authConfig.GetMetadata()))
 						errMessage += fmt.Sprintf("(Reason: %s)", status.GetReason())
 						multiErr = multierror.Append(multiErr, errors.New(errMessage))
+						recordCheckFailure(authConfig, errMessage)
 					case core.Status_Warning:
 						errMessage := fmt.Sprintf("Found auth config with warnings by '%s': %s ", reporter, renderMetadata(authConfig.GetMetadata()))
 						errMessage += fmt.Sprintf("(Reason: %s)", status.GetReason())
 						multiErr = multierror.Append(multiErr, errors.New(errMessage))
+						recordCheckFailure(authConfig, errMessage)
 					}
 				}
 				knownAuthConfigs = append(knownAuthConfigs, renderMetadata(authConfig.GetMetadata()))
@@ -512,15 +576,16 @@ func checkAuthConfigs(opts *options.Options, namespaces []string) ([]string, err
 		}
 	}
 	if multiErr != nil {
-		printer.AppendStatus("auth configs", fmt.Sprintf("%v Errors!", multiErr.Len()))
+		budget.appendStatus("auth configs", fmt.Sprintf("%v Errors!", multiErr.Len()))
 		return knownAuthConfigs, multiErr
 	}
-	printer.AppendStatus("auth configs", "OK")
+	budget.appendStatus("auth configs", "OK")
 	return knownAuthConfigs, nil
 }
 
 func checkRateLimitConfigs(opts *options.Options, namespaces []string) ([]string, error) {
 	printer.AppendCheck("Checking rate limit configs... ")
+	budget := newResourceBudget(opts)
 	var knownConfigs []string
 	var multiErr *multierror.Error
 	for _, ns := range namespaces {
@@ -540,10 +605,16 @@ func checkRateLimitConfigs(opts *options.Options, namespaces []string) ([]string
 			return nil, err
 		}
 		for _, config := range configs {
+			if !budget.take() {
+				// resources which are not examined can still be referenced
+				knownConfigs = append(knownConfigs, renderMetadata(config.GetMetadata()))
+				continue
+			}
 			if config.Status.GetState() == v1alpha1.RateLimitConfigStatus_REJECTED {
 				errMessage := fmt.Sprintf("Found rejected rate limit config: %s ", renderMetadata(config.GetMetadata()))
 				errMessage += fmt.Sprintf("(Reason: %s)", config.Status.GetMessage())
 				multiErr = multierror.Append(multiErr, fmt.Errorf(errMessage))
+				recordCheckFailure(config, errMessage)
 			}
 
 			knownConfigs = append(knownConfigs, renderMetadata(config.GetMetadata()))
@@ -551,16 +622,17 @@ func checkRateLimitConfigs(opts *options.Options, namespaces []string) ([]string
 	}
 
 	if multiErr != nil {
-		printer.AppendStatus("rate limit configs", fmt.Sprintf("%v Errors!", multiErr.Len()))
+		budget.appendStatus("rate limit configs", fmt.Sprintf("%v Errors!", multiErr.Len()))
 		return knownConfigs, multiErr
 	}
 
-	printer.AppendStatus("rate limit configs", "OK")
+	budget.appendStatus("rate limit configs", "OK")
 	return knownConfigs, nil
 }
 
 func checkVirtualHostOptions(opts *options.Options, namespaces []string) ([]string, error) {
 	printer.AppendCheck("Checking VirtualHostOptions... ")
+	budget := newResourceBudget(opts)
 	var knownVhOpts []string
 	var multiErr *multierror.Error
 	for _, ns := range namespaces {
@@ -578,6 +650,11 @@ func checkVirtualHostOptions(opts *options.Options, namespaces []string) ([]stri
 			return nil, err
 		}
 		for _, vhOpt := range vhOpts {
+			if !budget.take() {
+				// resources which are not examined can still be referenced
+				knownVhOpts = append(knownVhOpts, renderMetadata(vhOpt.GetMetadata()))
+				continue
+			}
 			if vhOpt.GetNamespacedStatuses() != nil {
 				namespacedStatuses := vhOpt.GetNamespacedStatuses()
 				for reporter, status := range namespacedStatuses.GetStatuses() {
@@ -586,10 +663,12 @@ func checkVirtualHostOptions(opts *options.Options, namespaces []string) ([]stri
 						errMessage := fmt.Sprintf("Found rejected VirtualHostOption by '%s': %s ", reporter, renderMetadata(vhOpt.GetMetadata()))
 						errMessage += fmt.Sprintf("(Reason: %s)", status.GetReason())
 						multiErr = multierror.Append(multiErr, errors.New(errMessage))
+						recordCheckFailure(vhOpt, errMessage)
 					case core.Status_Warning:
 						errMessage := fmt.Sprintf("Found VirtualHostOption with warnings by '%s': %s ", reporter, renderMetadata(vhOpt.GetMetadata()))
 						errMessage += fmt.Sprintf("(Reason: %s)", status.GetReason())
 						multiErr = multierror.Append(multiErr, errors.New(errMessage))
+						recordCheckFailure(vhOpt, errMessage)
 					}
 				}
 				knownVhOpts = append(knownVhOpts, renderMetadata(vhOpt.GetMetadata()))
@@ -597,15 +676,16 @@ func checkVirtualHostOptions(opts *options.Options, namespaces []string) ([]stri
 		}
 	}
 	if multiErr != nil {
-		printer.AppendStatus("VirtualHostOptions", fmt.Sprintf("%v Errors!", multiErr.Len()))
+		budget.appendStatus("VirtualHostOptions", fmt.Sprintf("%v Errors!", multiErr.Len()))
 		return knownVhOpts, multiErr
 	}
-	printer.AppendStatus("VirtualHostOptions", "OK")
+	budget.appendStatus("VirtualHostOptions", "OK")
 	return knownVhOpts, nil
 }
 
 func checkRouteOptions(opts *options.Options, namespaces []string) ([]string, error) {
 	printer.AppendCheck("Checking RouteOptions... ")
+	budget := newResourceBudget(opts)
 	var knownRouteOpts []string
 	var multiErr *multierror.Error
 	for _, ns := range namespaces {
@@ -623,6 +703,11 @@ func checkRouteOptions(opts *options.Options, namespaces []string) ([]string, er
 			return nil, err
 		}
 		for _, routeOpt := range routeOptions {
+			if !budget.take() {
+				// resources which are not examined can still be referenced
+				knownRouteOpts = append(knownRouteOpts, renderMetadata(routeOpt.GetMetadata()))
+				continue
+			}
 			if routeOpt.GetNamespacedStatuses() != nil {
 				namespacedStatuses := routeOpt.GetNamespacedStatuses()
 				for reporter, status := range namespacedStatuses.GetStatuses() {
@@ -631,10 +716,12 @@ func checkRouteOptions(opts *options.Options, namespaces []string) ([]string, er
 						errMessage := fmt.Sprintf("Found rejected RouteOption by '%s': %s ", reporter, renderMetadata(routeOpt.GetMetadata()))
 						errMessage += fmt.Sprintf("(Reason: %s)", status.GetReason())
 						multiErr = multierror.Append(multiErr, errors.New(errMessage))
+						recordCheckFailure(routeOpt, errMessage)
 					case core.Status_Warning:
 						errMessage := fmt.Sprintf("Found RouteOption with warnings by '%s': %s ", reporter, renderMetadata(routeOpt.GetMetadata()))
 						errMessage += fmt.Sprintf("(Reason: %s)", status.GetReason())
 						multiErr = multierror.Append(multiErr, errors.New(errMessage))
+						recordCheckFailure(routeOpt, errMessage)
 					}
 				}
 				knownRouteOpts = append(knownRouteOpts, renderMetadata(routeOpt.GetMetadata()))
@@ -642,15 +729,16 @@ func checkRouteOptions(opts *options.Options, namespaces []string) ([]string, er
 		}
 	}
 	if multiErr != nil {
-		printer.AppendStatus("RouteOptions", fmt.Sprintf("%v Errors!", multiErr.Len()))
+		budget.appendStatus("RouteOptions", fmt.Sprintf("%v Errors!", multiErr.Len()))
 		return knownRouteOpts, multiErr
 	}
-	printer.AppendStatus("RouteOptions", "OK")
+	budget.appendStatus("RouteOptions", "OK")
 	return knownRouteOpts, nil
 }
 
 func checkVirtualServices(opts *options.Options, namespaces, knownUpstreams, knownAuthConfigs, knownRateLimitConfigs, knownVirtualHostOptions, knownRouteOptions []string) error {
 	printer.AppendCheck("Checking virtual services... ")
+	budget := newResourceBudget(opts)
 	var multiErr *multierror.Error
 
 	for _, ns := range namespaces {
@@ -665,6 +753,9 @@ func checkVirtualServices(opts *options.Options, namespaces, knownUpstreams, kno
 			continue
 		}
 		for _, virtualService := range virtualServices {
+			if !budget.take() {
+				break
+			}
 			if virtualService.GetNamespacedStatuses() != nil {
 				namespacedStatuses := virtualService.GetNamespacedStatuses()
 				for reporter, status := range namespacedStatuses.GetStatuses() {
@@ -673,10 +764,12 @@ func checkVirtualServices(opts *options.Options, namespaces, knownUpstreams, kno
 						errMessage := fmt.Sprintf("Found rejected virtual service by '%s': %s ", reporter, renderMetadata(virtualService.GetMetadata()))
 						errMessage += fmt.Sprintf("(Reason: %s)", status.GetReason())
 						multiErr = multierror.Append(multiErr, fmt.Errorf(errMessage))
+						recordCheckFailure(virtualService, errMessage)
 					case core.Status_Warning:
 						errMessage := fmt.Sprintf("Found virtual service with warnings by '%s': %s ", reporter, renderMetadata(virtualService.GetMetadata()))
 						errMessage += fmt.Sprintf("(Reason: %s)", status.GetReason())
 						multiErr = multierror.Append(multiErr, fmt.Errorf(errMessage))
+						recordCheckFailure(virtualService, errMessage)
 					}
 				}
 			}
@@ -780,15 +873,16 @@ func checkVirtualServices(opts *options.Options, namespaces, knownUpstreams, kno
 	}
 
 	if multiErr != nil {
-		printer.AppendStatus("virtual services", fmt.Sprintf("%v Errors!", multiErr.Len()))
+		budget.appendStatus("virtual services", fmt.Sprintf("%v Errors!", multiErr.Len()))
 		return multiErr
 	}
-	printer.AppendStatus("virtual services", "OK")
+	budget.appendStatus("virtual services", "OK")
 	return nil
 }
 
 func checkGateways(opts *options.Options, namespaces []string) error {
 	printer.AppendCheck("Checking gateways... ")
+	budget := newResourceBudget(opts)
 	var multiErr *multierror.Error
 	for _, ns := range namespaces {
 		gatewayClient, err := helpers.GatewayClient(opts.Top.Ctx, []string{ns})
@@ -802,6 +896,9 @@ func checkGateways(opts *options.Options, namespaces []string) error {
 			continue
 		}
 		for _, gateway := range gateways {
+			if !budget.take() {
+				break
+			}
 			if gateway.GetNamespacedStatuses() != nil {
 				namespacedStatuses := gateway.GetNamespacedStatuses()
 				for reporter, status := range namespacedStatuses.GetStatuses() {
@@ -810,10 +907,12 @@ func checkGateways(opts *options.Options, namespaces []string) error {
 						errMessage := fmt.Sprintf("Found rejected gateway by '%s': %s\n", reporter, renderMetadata(gateway.GetMetadata()))
 						errMessage += fmt.Sprintf("Reason: %s\n", status.GetReason())
 						multiErr = multierror.Append(multiErr, fmt.Errorf(errMessage))
+						recordCheckFailure(gateway, errMessage)
 					case core.Status_Warning:
 						errMessage := fmt.Sprintf("Found gateway with warnings by '%s': %s\n", reporter, renderMetadata(gateway.GetMetadata()))
 						errMessage += fmt.Sprintf("Reason: %s\n", status.GetReason())
 						multiErr = multierror.Append(multiErr, fmt.Errorf(errMessage))
+						recordCheckFailure(gateway, errMessage)
 					}
 				}
 			}
@@ -821,11 +920,11 @@ func checkGateways(opts *options.Options, namespaces []string) error {
 	}
 
 	if multiErr != nil {
-		printer.AppendStatus("gateways", fmt.Sprintf("%v Errors!", multiErr.Len()))
+		budget.appendStatus("gateways", fmt.Sprintf("%v Errors!", multiErr.Len()))
 		return multiErr
 	}
 
-	printer.AppendStatus("gateways", "OK")
+	budget.appendStatus("gateways", "OK")
 	return nil
 }
 
@@ -839,6 +938,7 @@ func checkProxies(opts *options.Options, namespaces []string, glooNamespace stri
 		fmt.Println("Skipping due to an error in checking deployments")
 		return fmt.Errorf("proxy check was skipped due to an error in checking deployments")
 	}
+	budget := newResourceBudget(opts)
 	var multiErr *multierror.Error
 	for _, ns := range namespaces {
 		proxyClient, err := helpers.ProxyClient(opts.Top.Ctx, []string{ns})
@@ -852,6 +952,9 @@ func checkProxies(opts *options.Options, namespaces []string, glooNamespace stri
 			continue
 		}
 		for _, proxy := range proxies {
+			if !budget.take() {
+				break
+			}
 			if proxy.GetNamespacedStatuses() != nil {
 				namespacedStatuses := proxy.GetNamespacedStatuses()
 				for reporter, status := range namespacedStatuses.GetStatuses() {
@@ -860,10 +963,12 @@ func checkProxies(opts *options.Options, namespaces []string, glooNamespace stri
 						errMessage := fmt.Sprintf("Found rejected proxy by '%s': %s\n", reporter, renderMetadata(proxy.GetMetadata()))
 						errMessage += fmt.Sprintf("Reason: %s\n", status.GetReason())
 						multiErr = multierror.Append(multiErr, fmt.Errorf(errMessage))
+						recordCheckFailure(proxy, errMessage)
 					case core.Status_Warning:
 						errMessage := fmt.Sprintf("Found proxy with warnings by '%s': %s\n", reporter, renderMetadata(proxy.GetMetadata()))
 						errMessage += fmt.Sprintf("Reason: %s\n", status.GetReason())
 						multiErr = multierror.Append(multiErr, fmt.Errorf(errMessage))
+						recordCheckFailure(proxy, errMessage)
 					}
 				}
 			}
@@ -875,10 +980,10 @@ func checkProxies(opts *options.Options, namespaces []string, glooNamespace stri
 	}
 
 	if multiErr != nil {
-		printer.AppendStatus("proxies", fmt.Sprintf("%v Errors!", multiErr.Len()))
+		budget.appendStatus("proxies", fmt.Sprintf("%v Errors!", multiErr.Len()))
 		return multiErr
 	}
-	printer.AppendStatus("proxies", "OK")
+	budget.appendStatus("proxies", "OK")
 	return nil
 }
 
@@ -5,21 +5,41 @@ import (
 
 	gloostatusutils "github.com/solo-io/gloo/pkg/utils/statusutils"
 
+	"github.com/golang/protobuf/ptypes/duration"
+	"github.com/golang/protobuf/ptypes/wrappers"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	v12 "github.com/solo-io/gloo/projects/gateway/pkg/api/v1"
+	"github.com/solo-io/gloo/projects/gloo/cli/pkg/cmd/check"
 	"github.com/solo-io/gloo/projects/gloo/cli/pkg/helpers"
 	"github.com/solo-io/gloo/projects/gloo/cli/pkg/testutils"
 	v1 "github.com/solo-io/gloo/projects/gloo/pkg/api/v1"
+	"github.com/solo-io/gloo/projects/gloo/pkg/api/v1/options/kubernetes"
 	"github.com/solo-io/gloo/projects/gloo/pkg/defaults"
+	"github.com/solo-io/gloo/projects/gloo/pkg/plugins/tunneling"
 	"github.com/solo-io/solo-kit/pkg/api/v1/clients"
 	"github.com/solo-io/solo-kit/pkg/api/v1/resources"
 	"github.com/solo-io/solo-kit/pkg/api/v1/resources/core"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 )
 
+var defaultEventRecorderFactory = check.EventRecorderFactory
+
+// objectRecorder keeps the objects the events are emitted on, which the FakeRecorder drops
+type objectRecorder struct {
+	*record.FakeRecorder
+	objects []runtime.Object
+}
+
+func (r *objectRecorder) Event(object runtime.Object, eventtype, reason, message string) {
+	r.objects = append(r.objects, object)
+	r.FakeRecorder.Event(object, eventtype, reason, message)
+}
+
 var _ = Describe("Root", func() {
 
 	var (
@@ -40,6 +60,35 @@ var _ = Describe("Root", func() {
 		cancel()
 	})
 
+	// creates the gloo namespace, along with a deployment and the default settings, for the contexts exercising the
+	// included, tunneling and reporting checks
+	installGloo := func() {
+		client := helpers.MustKubeClient()
+		_, err := client.CoreV1().Namespaces().Create(ctx, &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: defaults.GlooSystem,
+			},
+		}, metav1.CreateOptions{})
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = client.AppsV1().Deployments("gloo-system").Create(ctx, &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "default",
+				Namespace: "gloo-system",
+			},
+			Spec: appsv1.DeploymentSpec{},
+		}, metav1.CreateOptions{})
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = helpers.MustNamespacedSettingsClient(ctx, "gloo-system").Write(&v1.Settings{
+			Metadata: &core.Metadata{
+				Name:      "default",
+				Namespace: "gloo-system",
+			},
+		}, clients.WriteOpts{})
+		Expect(err).NotTo(HaveOccurred())
+	}
+
 	Context("With a good kube client", func() {
 
 		It("all checks pass with OK status", func() {
@@ -397,4 +446,317 @@ var _ = Describe("Root", func() {
 
 	})
 
+	Context("Include", func() {
+
+		BeforeEach(func() {
+			installGloo()
+		})
+
+		Context("tunneling-connect-timeout", func() {
+
+			writeTunnelingUpstream := func(name string, connectionConfig *v1.ConnectionConfig) {
+				_, err := helpers.MustNamespacedUpstreamClient(ctx, "gloo-system").Write(&v1.Upstream{
+					Metadata: &core.Metadata{
+						Name:      name,
+						Namespace: "gloo-system",
+					},
+					HttpProxyHostname: &wrappers.StringValue{Value: "proxy.corp.com:3128"},
+					ConnectionConfig:  connectionConfig,
+				}, clients.WriteOpts{})
+				Expect(err).NotTo(HaveOccurred())
+			}
+
+			It("is not run unless included", func() {
+				writeTunnelingUpstream("default-timeout", nil)
+
+				output, err := testutils.GlooctlOut("check -x xds-metrics")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(output).NotTo(ContainSubstring("Checking tunneling connect timeouts..."))
+			})
+
+			It("warns about tunneling upstreams using the default connect timeout", func() {
+				writeTunnelingUpstream("default-timeout", nil)
+
+				output, err := testutils.GlooctlOut("check -x xds-metrics --include tunneling-connect-timeout")
+				Expect(err).NotTo(HaveOccurred(), "advisory checks should not fail glooctl check")
+				Expect(output).To(ContainSubstring("Checking tunneling connect timeouts... 1 Warnings"))
+				Expect(output).To(ContainSubstring("Warning: tunneling upstream gloo-system default-timeout uses the default 5s connect timeout"))
+				Expect(output).To(ContainSubstring("No problems detected."))
+			})
+
+			It("does not warn about tunneling upstreams with an explicit connect timeout", func() {
+				writeTunnelingUpstream("explicit-timeout", &v1.ConnectionConfig{
+					ConnectTimeout: &duration.Duration{Seconds: 30},
+				})
+
+				output, err := testutils.GlooctlOut("check -x xds-metrics --include tunneling-connect-timeout")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(output).To(ContainSubstring("Checking tunneling connect timeouts... OK"))
+				Expect(output).NotTo(ContainSubstring("explicit-timeout uses the default"))
+			})
+
+			Context("with a proxy service reference", func() {
+
+				// the tunneling upstream reaches its proxy through the upstream of the service, whose timeout applies
+				writeProxyServiceUpstreams := func(proxyConnectionConfig *v1.ConnectionConfig) {
+					upstreamClient := helpers.MustNamespacedUpstreamClient(ctx, "gloo-system")
+					_, err := upstreamClient.Write(&v1.Upstream{
+						Metadata: &core.Metadata{
+							Name:        "service-ref",
+							Namespace:   "gloo-system",
+							Annotations: map[string]string{tunneling.ProxyServiceAnnotation: "proxies/squid:3128"},
+						},
+						HttpProxyHostname: &wrappers.StringValue{Value: "proxy.corp.com:3128"},
+						ConnectionConfig: &v1.ConnectionConfig{
+							ConnectTimeout: &duration.Duration{Seconds: 30},
+						},
+					}, clients.WriteOpts{})
+					Expect(err).NotTo(HaveOccurred())
+					_, err = upstreamClient.Write(&v1.Upstream{
+						Metadata: &core.Metadata{
+							Name:      "proxies-squid-3128",
+							Namespace: "gloo-system",
+						},
+						UpstreamType: &v1.Upstream_Kube{
+							Kube: &kubernetes.UpstreamSpec{
+								ServiceName:      "squid",
+								ServiceNamespace: "proxies",
+								ServicePort:      3128,
+							},
+						},
+						ConnectionConfig: proxyConnectionConfig,
+					}, clients.WriteOpts{})
+					Expect(err).NotTo(HaveOccurred())
+				}
+
+				It("warns when the upstream of the proxy service uses the default connect timeout", func() {
+					writeProxyServiceUpstreams(nil)
+
+					output, err := testutils.GlooctlOut("check -x xds-metrics --include tunneling-connect-timeout")
+					Expect(err).NotTo(HaveOccurred())
+					Expect(output).To(ContainSubstring("Checking tunneling connect timeouts... 1 Warnings"))
+					Expect(output).To(ContainSubstring("Warning: tunneling upstream gloo-system service-ref reaches its HTTP CONNECT proxy " +
+						"through upstream gloo-system proxies-squid-3128, which uses the default 5s connect timeout"))
+				})
+
+				It("does not warn when the upstream of the proxy service has an explicit connect timeout", func() {
+					writeProxyServiceUpstreams(&v1.ConnectionConfig{
+						ConnectTimeout: &duration.Duration{Seconds: 30},
+					})
+
+					output, err := testutils.GlooctlOut("check -x xds-metrics --include tunneling-connect-timeout")
+					Expect(err).NotTo(HaveOccurred())
+					Expect(output).To(ContainSubstring("Checking tunneling connect timeouts... OK"))
+				})
+			})
+		})
+	})
+
+	Context("tunneling-pipe-paths", func() {
+
+		BeforeEach(func() {
+			installGloo()
+		})
+
+		writeTunnelingUpstream := func(name, namespace string, annotations map[string]string) {
+			_, err := helpers.MustNamespacedUpstreamClient(ctx, namespace).Write(&v1.Upstream{
+				Metadata: &core.Metadata{
+					Name:        name,
+					Namespace:   namespace,
+					Annotations: annotations,
+				},
+				HttpProxyHostname: &wrappers.StringValue{Value: "proxy.corp.com:3128"},
+			}, clients.WriteOpts{})
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		It("passes when tunneling upstreams generate distinct pipe paths", func() {
+			writeTunnelingUpstream("svc", "gloo-system", nil)
+			writeTunnelingUpstream("other-svc", "gloo-system", map[string]string{tunneling.TenantAnnotation: "tenant-a"})
+
+			output, err := testutils.GlooctlOut("check -x xds-metrics")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(output).To(ContainSubstring("Checking tunneling pipe paths... OK"))
+		})
+
+		It("errors when tunneling upstreams generate the same pipe path", func() {
+			_, err := helpers.MustNamespacedSettingsClient(ctx, "gloo-system").Write(&v1.Settings{
+				Metadata: &core.Metadata{
+					Name:      "default",
+					Namespace: "gloo-system",
+				},
+				WatchNamespaces: []string{"gloo-system", "c", "b_c"},
+			}, clients.WriteOpts{OverwriteExisting: true})
+			Expect(err).NotTo(HaveOccurred())
+			// both upstreams map to the cluster a_b_c
+			writeTunnelingUpstream("a_b", "c", nil)
+			writeTunnelingUpstream("a", "b_c", nil)
+
+			output, err := testutils.GlooctlOut("check -x xds-metrics")
+			Expect(err).To(HaveOccurred())
+			Expect(output).To(ContainSubstring("Checking tunneling pipe paths... 1 Errors!"))
+			Expect(output).To(ContainSubstring("Found tunneling upstreams sharing the pipe @/a_b_c"))
+		})
+
+		It("is not run when excluded", func() {
+			output, err := testutils.GlooctlOut("check -x xds-metrics,tunneling-pipe-paths")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(output).NotTo(ContainSubstring("Checking tunneling pipe paths..."))
+		})
+	})
+
+	Context("emit-events", func() {
+
+		var recorder *objectRecorder
+
+		BeforeEach(func() {
+			recorder = &objectRecorder{FakeRecorder: record.NewFakeRecorder(10)}
+			check.EventRecorderFactory = func() (record.EventRecorder, func()) {
+				return recorder, func() {}
+			}
+
+			installGloo()
+		})
+
+		AfterEach(func() {
+			check.EventRecorderFactory = defaultEventRecorderFactory
+		})
+
+		writeRejectedUpstream := func() {
+			rejectedUpstream := &v1.Upstream{
+				Metadata: &core.Metadata{
+					Name:      "some-rejected-upstream",
+					Namespace: "gloo-system",
+				},
+			}
+			statusClient.SetStatus(rejectedUpstream, &core.Status{
+				State:      core.Status_Rejected,
+				Reason:     "I am a rejected upstream",
+				ReportedBy: "gateway",
+			})
+			_, err := helpers.MustNamespacedUpstreamClient(ctx, "gloo-system").Write(rejectedUpstream, clients.WriteOpts{})
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		It("emits an event on the resources failing a check", func() {
+			writeRejectedUpstream()
+
+			_, err := testutils.GlooctlOut("check -x xds-metrics --emit-events")
+			Expect(err).To(HaveOccurred())
+			Expect(recorder.Events).To(Receive(HavePrefix("Warning " + check.CheckFailedReason + " Found rejected upstream by 'gateway'")))
+			Expect(recorder.Events).NotTo(Receive())
+		})
+
+		It("emits an event on the deployments failing a check", func() {
+			_, err := helpers.MustKubeClient().AppsV1().Deployments("gloo-system").Create(ctx, &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "gloo",
+					Namespace: "gloo-system",
+					UID:       "gloo-deployment-uid",
+				},
+				Status: appsv1.DeploymentStatus{
+					Conditions: []appsv1.DeploymentCondition{{
+						Type:   appsv1.DeploymentAvailable,
+						Status: corev1.ConditionFalse,
+					}},
+				},
+			}, metav1.CreateOptions{})
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = testutils.GlooctlOut("check -x xds-metrics --emit-events")
+			Expect(err).To(HaveOccurred())
+			Expect(recorder.Events).To(Receive(HavePrefix("Warning " + check.CheckFailedReason + " Deployment gloo in namespace gloo-system is not available!")))
+			Expect(recorder.Events).NotTo(Receive())
+			// kubectl describe only lists the events referencing the UID of the object
+			Expect(recorder.objects).To(HaveLen(1))
+			Expect(recorder.objects[0]).To(BeAssignableToTypeOf(&corev1.ObjectReference{}))
+			Expect(recorder.objects[0].(*corev1.ObjectReference).UID).To(BeEquivalentTo("gloo-deployment-uid"))
+		})
+
+		It("emits an event on the pods failing a check", func() {
+			_, err := helpers.MustKubeClient().CoreV1().Pods("gloo-system").Create(ctx, &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "gloo-pod",
+					Namespace: "gloo-system",
+					UID:       "gloo-pod-uid",
+					Labels:    map[string]string{"gloo": "gloo"},
+				},
+				Status: corev1.PodStatus{
+					Conditions: []corev1.PodCondition{{
+						Type:   corev1.PodReady,
+						Status: corev1.ConditionFalse,
+					}},
+				},
+			}, metav1.CreateOptions{})
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = testutils.GlooctlOut("check -x xds-metrics --emit-events")
+			Expect(err).To(HaveOccurred())
+			Expect(recorder.Events).To(Receive(HavePrefix("Warning " + check.CheckFailedReason + " Pod gloo-pod in namespace gloo-system is not ready!")))
+			Expect(recorder.Events).NotTo(Receive())
+			Expect(recorder.objects).To(HaveLen(1))
+			Expect(recorder.objects[0]).To(BeAssignableToTypeOf(&corev1.ObjectReference{}))
+			Expect(recorder.objects[0].(*corev1.ObjectReference).UID).To(BeEquivalentTo("gloo-pod-uid"))
+		})
+
+		It("does not emit events when the checks pass", func() {
+			_, err := testutils.GlooctlOut("check -x xds-metrics --emit-events")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(recorder.Events).NotTo(Receive())
+		})
+
+		It("does not emit events unless requested", func() {
+			writeRejectedUpstream()
+
+			_, err := testutils.GlooctlOut("check -x xds-metrics")
+			Expect(err).To(HaveOccurred())
+			Expect(recorder.Events).NotTo(Receive())
+		})
+	})
+
+	Context("max-resources", func() {
+
+		BeforeEach(func() {
+			installGloo()
+
+			for _, name := range []string{"rejected-upstream-1", "rejected-upstream-2", "rejected-upstream-3"} {
+				rejectedUpstream := &v1.Upstream{
+					Metadata: &core.Metadata{
+						Name:      name,
+						Namespace: "gloo-system",
+					},
+				}
+				statusClient.SetStatus(rejectedUpstream, &core.Status{
+					State:      core.Status_Rejected,
+					Reason:     "I am a rejected upstream",
+					ReportedBy: "gateway",
+				})
+				_, err = helpers.MustNamespacedUpstreamClient(ctx, "gloo-system").Write(rejectedUpstream, clients.WriteOpts{})
+				Expect(err).NotTo(HaveOccurred())
+			}
+		})
+
+		It("examines every resource by default", func() {
+			output, err := testutils.GlooctlOut("check -x xds-metrics")
+			Expect(err).To(HaveOccurred())
+			Expect(output).To(ContainSubstring("Checking upstreams... 3 Errors!"))
+			Expect(output).NotTo(ContainSubstring("results are partial"))
+		})
+
+		It("caps the number of resources examined by each check", func() {
+			output, err := testutils.GlooctlOut("check -x xds-metrics --max-resources 2")
+			Expect(err).To(HaveOccurred())
+			Expect(output).To(ContainSubstring("Checking upstreams... 2 Errors!"))
+			Expect(output).To(ContainSubstring("Warning: only the first 2 upstreams were checked (--max-resources), results are partial"))
+			Expect(output).To(ContainSubstring("Checking deployments... OK"))
+			Expect(output).NotTo(ContainSubstring("only the first 2 deployments were checked"))
+		})
+
+		It("errors on a negative cap", func() {
+			_, err := testutils.GlooctlOut("check -x xds-metrics --max-resources -1")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Invalid max resources"))
+		})
+	})
+
 })
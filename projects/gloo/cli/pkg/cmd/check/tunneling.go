@@ -0,0 +1,150 @@
+package check
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/rotisserie/eris"
+	"github.com/solo-io/gloo/projects/gloo/cli/pkg/cmd/options"
+	"github.com/solo-io/gloo/projects/gloo/cli/pkg/helpers"
+	v1 "github.com/solo-io/gloo/projects/gloo/pkg/api/v1"
+	"github.com/solo-io/gloo/projects/gloo/pkg/plugins/tunneling"
+	"github.com/solo-io/gloo/projects/gloo/pkg/translator"
+	"github.com/solo-io/solo-kit/pkg/api/v1/clients"
+)
+
+// checkTunnelingConnectTimeouts warns about tunneling upstreams which rely on the default connect timeout.
+// The default is often too low when the HTTP CONNECT proxy is a corporate proxy in a high-latency environment.
+// Findings are reported as warnings; only failures to list upstreams are returned as errors.
+func checkTunnelingConnectTimeouts(opts *options.Options, namespaces []string, settings *v1.Settings) error {
+	printer.AppendCheck("Checking tunneling connect timeouts... ")
+	budget := newResourceBudget(opts)
+	var multiErr *multierror.Error
+	var upstreams v1.UpstreamList
+	for _, ns := range namespaces {
+		client, err := helpers.UpstreamClient(opts.Top.Ctx, []string{ns})
+		if err != nil {
+			multiErr = multierror.Append(multiErr, err)
+			continue
+		}
+		nsUpstreams, err := client.List(ns, clients.ListOpts{})
+		if err != nil {
+			multiErr = multierror.Append(multiErr, err)
+			continue
+		}
+		upstreams = append(upstreams, nsUpstreams...)
+	}
+	warnings := tunnelingConnectTimeoutWarnings(upstreams, settings, budget)
+	if multiErr != nil {
+		budget.appendStatus("tunneling connect timeouts", fmt.Sprintf("%v Errors!", multiErr.Len()))
+		return multiErr
+	}
+	if len(warnings) == 0 {
+		budget.appendStatus("tunneling connect timeouts", "OK")
+		return nil
+	}
+	budget.appendStatus("tunneling connect timeouts", fmt.Sprintf("%v Warnings", len(warnings)))
+	for _, warning := range warnings {
+		printer.AppendMessage(warning)
+	}
+	return nil
+}
+
+// the connect timeout which applies is the one of the upstream reaching the HTTP CONNECT proxy: the tunneling upstream
+// itself, or the upstream of the proxy service it references. Upstreams are examined within the budget, but proxy
+// service references are resolved against all the upstreams.
+func tunnelingConnectTimeoutWarnings(upstreams v1.UpstreamList, settings *v1.Settings, budget *resourceBudget) []string {
+	upstreamsByCluster := map[string]*v1.Upstream{}
+	for _, upstream := range upstreams {
+		upstreamsByCluster[translator.UpstreamToClusterName(upstream.GetMetadata().Ref())] = upstream
+	}
+
+	var warnings []string
+	for _, upstream := range upstreams {
+		if !budget.take() {
+			break
+		}
+		cfg, err := tunneling.EffectiveTunnelingConfig(upstream, upstreams, settings, tunneling.ExperimentalConfig{})
+		if err != nil || cfg == nil {
+			// misconfigured upstreams are reported by translation, and are not tunneled
+			continue
+		}
+		proxyUpstream := upstreamsByCluster[cfg.ProxyCluster]
+		if proxyUpstream.GetConnectionConfig().GetConnectTimeout() != nil {
+			continue
+		}
+		if proxyUpstream == upstream {
+			warnings = append(warnings, fmt.Sprintf("Warning: tunneling upstream %s uses the default %s connect timeout to reach "+
+				"HTTP CONNECT proxy %s. Consider setting connectionConfig.connectTimeout explicitly for high-latency proxies.",
+				renderMetadata(upstream.GetMetadata()), translator.ClusterConnectionTimeout, upstream.GetHttpProxyHostname().GetValue()))
+			continue
+		}
+		warnings = append(warnings, fmt.Sprintf("Warning: tunneling upstream %s reaches its HTTP CONNECT proxy through upstream %s, "+
+			"which uses the default %s connect timeout. Consider setting connectionConfig.connectTimeout explicitly on it for high-latency proxies.",
+			renderMetadata(upstream.GetMetadata()), renderMetadata(proxyUpstream.GetMetadata()), translator.ClusterConnectionTimeout))
+	}
+	return warnings
+}
+
+// checkTunnelingPipePaths errors if distinct tunneling upstreams generate the same in-memory pipe, which would make
+// envoy cross their traffic. The pipe path is derived from the cluster name of the upstream, so upstreams whose names
+// map to the same cluster name collide.
+func checkTunnelingPipePaths(opts *options.Options, namespaces []string, settings *v1.Settings) error {
+	printer.AppendCheck("Checking tunneling pipe paths... ")
+	budget := newResourceBudget(opts)
+	var multiErr *multierror.Error
+	var upstreams v1.UpstreamList
+	for _, ns := range namespaces {
+		client, err := helpers.UpstreamClient(opts.Top.Ctx, []string{ns})
+		if err != nil {
+			multiErr = multierror.Append(multiErr, err)
+			continue
+		}
+		nsUpstreams, err := client.List(ns, clients.ListOpts{})
+		if err != nil {
+			multiErr = multierror.Append(multiErr, err)
+			continue
+		}
+		upstreams = append(upstreams, nsUpstreams...)
+	}
+	for _, err := range tunnelingPipePathCollisions(upstreams, settings, budget) {
+		multiErr = multierror.Append(multiErr, err)
+	}
+	if multiErr != nil {
+		budget.appendStatus("tunneling pipe paths", fmt.Sprintf("%v Errors!", multiErr.Len()))
+		return multiErr
+	}
+	budget.appendStatus("tunneling pipe paths", "OK")
+	return nil
+}
+
+// upstreams are examined within the budget, but proxy service references are resolved against all the upstreams
+func tunnelingPipePathCollisions(upstreams v1.UpstreamList, settings *v1.Settings, budget *resourceBudget) []error {
+	upstreamsByPipe := map[string][]string{}
+	for _, upstream := range upstreams {
+		if !budget.take() {
+			break
+		}
+		cfg, err := tunneling.EffectiveTunnelingConfig(upstream, upstreams, settings, tunneling.ExperimentalConfig{})
+		if err != nil || cfg == nil {
+			// misconfigured upstreams are reported by translation, and are not assigned a pipe
+			continue
+		}
+		upstreamsByPipe[cfg.PipePath] = append(upstreamsByPipe[cfg.PipePath], renderMetadata(upstream.GetMetadata()))
+	}
+
+	var pipes []string
+	for pipe, pipeUpstreams := range upstreamsByPipe {
+		if len(pipeUpstreams) > 1 {
+			pipes = append(pipes, pipe)
+		}
+	}
+	sort.Strings(pipes)
+
+	var errs []error
+	for _, pipe := range pipes {
+		errs = append(errs, eris.Errorf("Found tunneling upstreams sharing the pipe %s: %v", pipe, upstreamsByPipe[pipe]))
+	}
+	return errs
+}
@@ -60,6 +60,7 @@ func RootCmd(opts *options.Options, optionsFunc ...cliutils.OptionsFunc) *cobra.
 	}
 	flagutils.AddFileFlag(cmd.LocalFlags(), &opts.Top.File)
 	flagutils.AddOutputFlag(cmd.PersistentFlags(), &opts.Top.Output)
+	flagutils.RegisterOutputFlagCompletion(cmd, flagutils.ResourceOutputTypes)
 	flagutils.AddMetadataFlags(cmd.PersistentFlags(), &opts.Metadata)
 	flagutils.AddDryRunFlag(cmd.PersistentFlags(), &opts.Create.DryRun)
 
@@ -52,6 +52,7 @@ func RootCmd(opts *options.Options, optionsFunc ...cliutils.OptionsFunc) *cobra.
 	pflags := cmd.PersistentFlags()
 	flagutils.AddMetadataFlags(pflags, &opts.Metadata)
 	flagutils.AddOutputFlag(pflags, &opts.Top.Output)
+	flagutils.RegisterOutputFlagCompletion(cmd, flagutils.GetOutputTypes)
 
 	cmd.AddCommand(VirtualService(opts))
 	cmd.AddCommand(RouteTable(opts))
@@ -451,4 +451,10 @@ type Register struct {
 type Check struct {
 	// The maximum length of time to wait before giving up on a secret request. A value of zero means no timeout.
 	SecretClientTimeout time.Duration
+	// Advisory checks which do not run unless requested explicitly.
+	IncludeChecks []string
+	// Emit kubernetes events on the resources which fail a check.
+	EmitEvents bool
+	// Maximum number of resources examined by each check, 0 for no limit.
+	MaxResources int
 }
@@ -42,6 +42,7 @@ func Route(opts *options.Options, optionsFunc ...cliutils.OptionsFunc) *cobra.Co
 	}
 	pflags := cmd.PersistentFlags()
 	flagutils.AddOutputFlag(pflags, &opts.Top.Output)
+	flagutils.RegisterOutputFlagCompletion(cmd, flagutils.ResourceOutputTypes)
 	flagutils.RemoveRouteFlags(pflags, &opts.Remove.Route)
 	cliutils.ApplyOptions(cmd, optionsFunc)
 	return cmd
@@ -40,6 +40,7 @@ func Sort(opts *options.Options, optionsFunc ...cliutils.OptionsFunc) *cobra.Com
 	}
 	pflags := cmd.PersistentFlags()
 	flagutils.AddOutputFlag(pflags, &opts.Top.Output)
+	flagutils.RegisterOutputFlagCompletion(cmd, flagutils.ResourceOutputTypes)
 	cliutils.ApplyOptions(cmd, optionsFunc)
 	return cmd
 }
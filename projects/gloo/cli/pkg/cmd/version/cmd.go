@@ -56,6 +56,7 @@ func RootCmd(opts *options.Options, optionsFunc ...cliutils.OptionsFunc) *cobra.
 
 	pflags := cmd.PersistentFlags()
 	flagutils.AddOutputFlag(pflags, &versionOutput)
+	flagutils.RegisterOutputFlagCompletion(cmd, flagutils.VersionOutputTypes)
 	flagutils.AddNamespaceFlag(pflags, &opts.Metadata.Namespace)
 
 	return cmd
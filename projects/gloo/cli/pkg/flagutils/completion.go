@@ -0,0 +1,42 @@
+package flagutils
+
+import (
+	"context"
+	"strings"
+
+	"github.com/solo-io/gloo/projects/gloo/cli/pkg/printers"
+	"github.com/solo-io/go-utils/contextutils"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// output types supported by glooctl check
+	CheckOutputTypes = []printers.OutputType{printers.JSON, printers.TABLE}
+	// output types supported by glooctl version
+	VersionOutputTypes = []printers.OutputType{printers.YAML, printers.JSON, printers.TABLE}
+	// output types supported by the commands printing the resources they create or modify
+	ResourceOutputTypes = []printers.OutputType{printers.YAML, printers.JSON, printers.TABLE, printers.KUBE_YAML}
+	// output types supported by glooctl get. wide adds the xds details of upstreams, other resources print it as a table
+	GetOutputTypes = []printers.OutputType{printers.YAML, printers.JSON, printers.TABLE, printers.KUBE_YAML, printers.WIDE}
+)
+
+// RegisterOutputFlagCompletion completes the values of the output flag of the command with the output types it supports
+func RegisterOutputFlagCompletion(cmd *cobra.Command, outputTypes []printers.OutputType) {
+	if err := cmd.RegisterFlagCompletionFunc(OutputFlag, OutputFlagCompletion(outputTypes)); err != nil {
+		// the output flag must be added to the command before registering its completion
+		contextutils.LoggerFrom(context.TODO()).DPanicf("failed to register output flag completion for %s: %v", cmd.Name(), err)
+	}
+}
+
+// OutputFlagCompletion offers the names of the given output types starting with the value being completed
+func OutputFlagCompletion(outputTypes []printers.OutputType) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		var completions []string
+		for _, outputType := range outputTypes {
+			if name := outputType.Name(); strings.HasPrefix(name, toComplete) {
+				completions = append(completions, name)
+			}
+		}
+		return completions, cobra.ShellCompDirectiveNoFileComp
+	}
+}
@@ -0,0 +1,50 @@
+package flagutils_test
+
+import (
+	"bytes"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/solo-io/gloo/projects/gloo/cli/pkg/flagutils"
+	"github.com/solo-io/gloo/projects/gloo/cli/pkg/printers"
+	"github.com/spf13/cobra"
+)
+
+var _ = Describe("Output flag completion", func() {
+
+	It("offers the output types supported by the command", func() {
+		completions, directive := flagutils.OutputFlagCompletion(flagutils.ResourceOutputTypes)(nil, nil, "")
+		Expect(completions).To(Equal([]string{"yaml", "json", "table", "kube-yaml"}))
+		Expect(directive).To(Equal(cobra.ShellCompDirectiveNoFileComp))
+
+		completions, _ = flagutils.OutputFlagCompletion(flagutils.CheckOutputTypes)(nil, nil, "")
+		Expect(completions).To(Equal([]string{"json", "table"}))
+
+		completions, _ = flagutils.OutputFlagCompletion(flagutils.VersionOutputTypes)(nil, nil, "")
+		Expect(completions).To(Equal([]string{"yaml", "json", "table"}))
+	})
+
+	It("only offers the output types matching the value being completed", func() {
+		completions, _ := flagutils.OutputFlagCompletion(flagutils.ResourceOutputTypes)(nil, nil, "t")
+		Expect(completions).To(Equal([]string{"table"}))
+	})
+
+	It("registers the completion on the output flag of the command", func() {
+		var outputType printers.OutputType
+		cmd := &cobra.Command{
+			Use: "check",
+			Run: func(cmd *cobra.Command, args []string) {},
+		}
+		flagutils.AddCheckOutputFlag(cmd.PersistentFlags(), &outputType)
+		flagutils.RegisterOutputFlagCompletion(cmd, flagutils.CheckOutputTypes)
+
+		out := new(bytes.Buffer)
+		cmd.SetOut(out)
+		cmd.SetArgs([]string{cobra.ShellCompRequestCmd, "-o", ""})
+		Expect(cmd.Execute()).To(Succeed())
+
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		Expect(lines).To(Equal([]string{"json", "table", ":4"}))
+	})
+})
@@ -0,0 +1,93 @@
+package flagutils
+
+import (
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/rotisserie/eris"
+	"github.com/spf13/pflag"
+)
+
+var (
+	MutuallyExclusiveFlagsError = func(set []string) error {
+		return eris.Errorf("flags %s cannot be used together", joinFlags(set))
+	}
+	RequiredTogetherFlagsError = func(set, missing []string) error {
+		return eris.Errorf("flags %s must be used together, missing %s", joinFlags(append(set, missing...)), joinFlags(missing))
+	}
+	RequiredFlagsError = func(flag string, missing []string) error {
+		return eris.Errorf("flag --%s requires %s", flag, joinFlags(missing))
+	}
+)
+
+// FlagRule validates a combination of flags that were explicitly set on a command
+type FlagRule func(set *pflag.FlagSet) error
+
+// MutuallyExclusive flags cannot be set together, e.g. `--dry-run` and `--watch`
+func MutuallyExclusive(flags ...string) FlagRule {
+	return func(set *pflag.FlagSet) error {
+		if changed := changedFlags(set, flags); len(changed) > 1 {
+			return MutuallyExclusiveFlagsError(changed)
+		}
+		return nil
+	}
+}
+
+// RequiredTogether flags must either all be set, or none of them
+func RequiredTogether(flags ...string) FlagRule {
+	return func(set *pflag.FlagSet) error {
+		changed := changedFlags(set, flags)
+		if len(changed) == 0 || len(changed) == len(flags) {
+			return nil
+		}
+		return RequiredTogetherFlagsError(changed, unchangedFlags(set, flags))
+	}
+}
+
+// Requires flags to be set whenever the given flag is set
+func Requires(flag string, required ...string) FlagRule {
+	return func(set *pflag.FlagSet) error {
+		if !set.Changed(flag) {
+			return nil
+		}
+		if missing := unchangedFlags(set, required); len(missing) > 0 {
+			return RequiredFlagsError(flag, missing)
+		}
+		return nil
+	}
+}
+
+// ValidateFlagRules returns an error describing every rule violated by the flags set on a command
+func ValidateFlagRules(set *pflag.FlagSet, rules ...FlagRule) error {
+	var multiErr *multierror.Error
+	for _, rule := range rules {
+		if err := rule(set); err != nil {
+			multiErr = multierror.Append(multiErr, err)
+		}
+	}
+	return multiErr.ErrorOrNil()
+}
+
+func changedFlags(set *pflag.FlagSet, flags []string) []string {
+	var changed []string
+	for _, flag := range flags {
+		if set.Changed(flag) {
+			changed = append(changed, flag)
+		}
+	}
+	return changed
+}
+
+func unchangedFlags(set *pflag.FlagSet, flags []string) []string {
+	var unchanged []string
+	for _, flag := range flags {
+		if !set.Changed(flag) {
+			unchanged = append(unchanged, flag)
+		}
+	}
+	return unchanged
+}
+
+func joinFlags(flags []string) string {
+	return "--" + strings.Join(flags, ", --")
+}
@@ -0,0 +1,97 @@
+package flagutils_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/solo-io/gloo/projects/gloo/cli/pkg/flagutils"
+	"github.com/spf13/pflag"
+)
+
+var _ = Describe("FlagRules", func() {
+
+	var (
+		set *pflag.FlagSet
+	)
+
+	BeforeEach(func() {
+		set = pflag.NewFlagSet("test", pflag.ContinueOnError)
+		set.Bool("dry-run", false, "")
+		set.Bool("watch", false, "")
+		set.Bool("all-namespaces", false, "")
+		set.StringP("namespace", "n", "gloo-system", "")
+		set.String("username", "", "")
+		set.String("password", "", "")
+	})
+
+	parse := func(args ...string) {
+		Expect(set.Parse(args)).To(Succeed())
+	}
+
+	Context("MutuallyExclusive", func() {
+
+		It("allows setting one of the flags", func() {
+			parse("--dry-run")
+			Expect(flagutils.ValidateFlagRules(set, flagutils.MutuallyExclusive("dry-run", "watch"))).To(Succeed())
+		})
+
+		It("errors when several of the flags are set", func() {
+			parse("--dry-run", "--watch")
+			err := flagutils.ValidateFlagRules(set, flagutils.MutuallyExclusive("dry-run", "watch"))
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("flags --dry-run, --watch cannot be used together"))
+		})
+
+		It("only considers flags set explicitly", func() {
+			parse("--all-namespaces")
+			Expect(flagutils.ValidateFlagRules(set, flagutils.MutuallyExclusive("all-namespaces", "namespace"))).To(Succeed())
+
+			parse("-n", "default")
+			err := flagutils.ValidateFlagRules(set, flagutils.MutuallyExclusive("all-namespaces", "namespace"))
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("flags --all-namespaces, --namespace cannot be used together"))
+		})
+	})
+
+	Context("RequiredTogether", func() {
+
+		It("allows setting none or all of the flags", func() {
+			Expect(flagutils.ValidateFlagRules(set, flagutils.RequiredTogether("username", "password"))).To(Succeed())
+
+			parse("--username", "admin", "--password", "admin")
+			Expect(flagutils.ValidateFlagRules(set, flagutils.RequiredTogether("username", "password"))).To(Succeed())
+		})
+
+		It("errors when only some of the flags are set", func() {
+			parse("--username", "admin")
+			err := flagutils.ValidateFlagRules(set, flagutils.RequiredTogether("username", "password"))
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("flags --username, --password must be used together, missing --password"))
+		})
+	})
+
+	Context("Requires", func() {
+
+		It("does not apply when the flag is not set", func() {
+			parse("--password", "admin")
+			Expect(flagutils.ValidateFlagRules(set, flagutils.Requires("username", "password"))).To(Succeed())
+		})
+
+		It("errors when the required flags are missing", func() {
+			parse("--username", "admin")
+			err := flagutils.ValidateFlagRules(set, flagutils.Requires("username", "password"))
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("flag --username requires --password"))
+		})
+	})
+
+	It("reports every violated rule", func() {
+		parse("--dry-run", "--watch", "--username", "admin")
+		err := flagutils.ValidateFlagRules(set,
+			flagutils.MutuallyExclusive("dry-run", "watch"),
+			flagutils.RequiredTogether("username", "password"),
+		)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("cannot be used together"))
+		Expect(err.Error()).To(ContainSubstring("must be used together"))
+	})
+})
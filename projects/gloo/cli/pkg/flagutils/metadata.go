@@ -31,5 +31,17 @@ func AddResourceNamespaceFlag(set *pflag.FlagSet, strptr *[]string) {
 }
 
 func AddExcludeCheckFlag(set *pflag.FlagSet, strarrptr *[]string) {
-	set.StringSliceVarP(strarrptr, "exclude", "x", []string{}, "check to exclude: (deployments, pods, upstreams, upstreamgroup, auth-configs, rate-limit-configs, secrets, virtual-services, gateways, proxies, xds-metrics)")
+	set.StringSliceVarP(strarrptr, "exclude", "x", []string{}, "check to exclude: (deployments, pods, upstreams, tunneling-pipe-paths, upstreamgroup, auth-configs, rate-limit-configs, secrets, virtual-services, gateways, proxies, xds-metrics)")
+}
+
+func AddEmitEventsFlag(set *pflag.FlagSet, boolptr *bool) {
+	set.BoolVar(boolptr, "emit-events", false, "emit kubernetes events on the resources which fail a check")
+}
+
+func AddMaxResourcesFlag(set *pflag.FlagSet, intptr *int) {
+	set.IntVar(intptr, "max-resources", 0, "maximum number of resources examined by each check, results are partial when reached (0 for no limit)")
+}
+
+func AddIncludeCheckFlag(set *pflag.FlagSet, strarrptr *[]string) {
+	set.StringSliceVar(strarrptr, "include", []string{}, "opt-in advisory check to include: (tunneling-connect-timeout)")
 }
@@ -32,6 +32,7 @@ type P struct {
 }
 
 func (p P) AppendCheck(name string) {
+	name = RedactSecrets(name)
 	if p.OutputType.IsTable() {
 		fmt.Printf(name)
 	} else if p.OutputType.IsJSON() {
@@ -41,6 +42,7 @@ func (p P) AppendCheck(name string) {
 }
 
 func (p P) AppendStatus(name string, status string) {
+	status = RedactSecrets(status)
 
 	if p.OutputType.IsTable() {
 		fmt.Printf(status + "\n")
@@ -55,6 +57,7 @@ func (p P) AppendStatus(name string, status string) {
 }
 
 func (p P) AppendMessage(message string) {
+	message = RedactSecrets(message)
 	if p.OutputType.IsTable() {
 		fmt.Printf(message + "\n")
 	} else if p.OutputType.IsJSON() {
@@ -63,6 +66,7 @@ func (p P) AppendMessage(message string) {
 }
 
 func (p P) AppendError(err string) {
+	err = RedactSecrets(err)
 	if p.OutputType.IsTable() {
 		// errors are returned by the root cmd, no need to print them here
 		// fmt.Printf(err)
@@ -78,6 +78,16 @@ func (o *OutputType) String() string {
 	return _OutputValueToType[*o]
 }
 
+// Name returns the default name of the output type, which is accepted when setting it
+func (o OutputType) Name() string {
+	for _, tp := range typeProperties {
+		if tp.outputType == o {
+			return tp.names[0]
+		}
+	}
+	return ""
+}
+
 func (o *OutputType) Set(s string) error {
 	val, ok := _OutputTypeToValue[s]
 	if !ok {
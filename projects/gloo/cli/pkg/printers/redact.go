@@ -0,0 +1,74 @@
+package printers
+
+import (
+	"regexp"
+	"unicode"
+)
+
+const (
+	redacted = "<redacted>"
+	// credential values shorter than this are words rather than secrets, e.g. `token: expired`
+	minSecretLength = 8
+)
+
+var (
+	// PEM encoded private keys, e.g. the tls.key of a kubernetes TLS secret.
+	// certificates are left untouched, as their metadata (expiry, subject) is useful when debugging.
+	privateKeyPattern = regexp.MustCompile(`(?s)(-----BEGIN [A-Z ]*PRIVATE KEY-----).*?(-----END [A-Z ]*PRIVATE KEY-----)`)
+	// key/value pairs holding credentials, in yaml, json or flag form, e.g. `token: abc`, `"apiKey":"abc"` or `password=abc`
+	credentialPattern = regexp.MustCompile(`(?i)((?:token|password|secret[_-]?key|api[_-]?key|private[_-]?key)["']?\s*[:=]\s*["']?)([^\s"',}]+)`)
+	// HTTP authorization header values
+	bearerPattern = regexp.MustCompile(`(?i)(bearer\s+)([^\s"',]+)`)
+	// JSON web tokens, as three base64url encoded segments, the first one being a JSON object
+	jwtPattern = regexp.MustCompile(`^eyJ[A-Za-z0-9_-]*\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*$`)
+)
+
+// RedactSecrets masks secret material (private keys and credential values) in check output,
+// so that reporting on secrets never leaks them to the terminal or to machine-readable output.
+// Only credential values which look like secrets are masked, so that messages about credentials, e.g.
+// "Bearer token validation failed", stay readable.
+func RedactSecrets(s string) string {
+	s = privateKeyPattern.ReplaceAllString(s, "${1}"+redacted+"${2}")
+	s = redactSecretValues(credentialPattern, s)
+	return redactSecretValues(bearerPattern, s)
+}
+
+// redacts the value matched by the second group of the pattern, if it looks like a secret
+func redactSecretValues(pattern *regexp.Regexp, s string) string {
+	return pattern.ReplaceAllStringFunc(s, func(match string) string {
+		groups := pattern.FindStringSubmatch(match)
+		if !looksLikeSecret(groups[2]) {
+			return match
+		}
+		return groups[1] + redacted
+	})
+}
+
+// secrets are either JSON web tokens, or long enough values mixing lowercase, uppercase (past the first character) and
+// digits, as generated tokens and keys do while words do not
+func looksLikeSecret(value string) bool {
+	if jwtPattern.MatchString(value) {
+		return true
+	}
+	if len(value) < minSecretLength {
+		return false
+	}
+	var lower, upper, digit bool
+	for i, r := range value {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r) && i > 0:
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		}
+	}
+	classes := 0
+	for _, present := range []bool{lower, upper, digit} {
+		if present {
+			classes++
+		}
+	}
+	return classes >= 2
+}
@@ -12,6 +12,7 @@ import (
 	envoyhttp "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
 	v1 "github.com/solo-io/gloo/projects/gloo/pkg/api/v1"
 	v1snap "github.com/solo-io/gloo/projects/gloo/pkg/api/v1/gloosnapshot"
+	envoycache "github.com/solo-io/solo-kit/pkg/api/v1/control-plane/cache"
 )
 
 // Plugin is a named unit of translation, used to produce Envoy configuration
@@ -153,6 +154,17 @@ type ResourceGeneratorPlugin interface {
 	) ([]*envoy_config_cluster_v3.Cluster, []*envoy_config_endpoint_v3.ClusterLoadAssignment, []*envoy_config_route_v3.RouteConfiguration, []*envoy_config_listener_v3.Listener, error)
 }
 
+/*
+	Validation plugins
+*/
+
+// GeneratedResourceValidatorPlugin checks the xDS resources produced by a translation, so that resources
+// Envoy would reject can be reported during validation, before they are ever sent to Envoy
+type GeneratedResourceValidatorPlugin interface {
+	Plugin
+	ValidateGeneratedResources(snap envoycache.Snapshot) error
+}
+
 // A PluginRegistry is used to provide Plugins to relevant translators
 // Historically, all plugins were passed around as an argument, and each translator
 // would iterate over all plugins, and only apply the relevant ones.
@@ -170,6 +182,7 @@ type PluginRegistry interface {
 	GetRoutePlugins() []RoutePlugin
 	GetRouteActionPlugins() []RouteActionPlugin
 	GetWeightedDestinationPlugins() []WeightedDestinationPlugin
+	GetGeneratedResourceValidatorPlugins() []GeneratedResourceValidatorPlugin
 }
 
 // A PluginRegistryFactory generates a PluginRegistry
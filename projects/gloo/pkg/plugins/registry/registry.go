@@ -103,7 +103,7 @@ func Plugins(opts bootstrap.Opts) []plugins.Plugin {
 		protocoloptions.NewPlugin(),
 		grpcjson.NewPlugin(),
 		metadata.NewPlugin(),
-		tunneling.NewPlugin(),
+		tunneling.NewPlugin(tunneling.WithExperimentalConfig(tunneling.ExperimentalConfigFromEnv())),
 		dynamic_forward_proxy.NewPlugin(),
 	)
 
@@ -147,6 +147,7 @@ type pluginRegistry struct {
 	routePlugins                 []plugins.RoutePlugin
 	routeActionPlugins           []plugins.RouteActionPlugin
 	weightedDestinationPlugins   []plugins.WeightedDestinationPlugin
+	generatedResourceValidators  []plugins.GeneratedResourceValidatorPlugin
 }
 
 // NewPluginRegistry creates a plugin registry and places all registered plugins
@@ -165,6 +166,7 @@ func NewPluginRegistry(registeredPlugins []plugins.Plugin) *pluginRegistry {
 	var routePlugins []plugins.RoutePlugin
 	var routeActionPlugins []plugins.RouteActionPlugin
 	var weightedDestinationPlugins []plugins.WeightedDestinationPlugin
+	var generatedResourceValidators []plugins.GeneratedResourceValidatorPlugin
 
 	// Process registered plugins once
 	for _, plugin := range registeredPlugins {
@@ -227,6 +229,11 @@ func NewPluginRegistry(registeredPlugins []plugins.Plugin) *pluginRegistry {
 		if ok {
 			weightedDestinationPlugins = append(weightedDestinationPlugins, weightedDestinationPlugin)
 		}
+
+		generatedResourceValidator, ok := plugin.(plugins.GeneratedResourceValidatorPlugin)
+		if ok {
+			generatedResourceValidators = append(generatedResourceValidators, generatedResourceValidator)
+		}
 	}
 
 	return &pluginRegistry{
@@ -242,6 +249,7 @@ func NewPluginRegistry(registeredPlugins []plugins.Plugin) *pluginRegistry {
 		routePlugins:                 routePlugins,
 		routeActionPlugins:           routeActionPlugins,
 		weightedDestinationPlugins:   weightedDestinationPlugins,
+		generatedResourceValidators:  generatedResourceValidators,
 	}
 }
 
@@ -304,3 +312,8 @@ func (p *pluginRegistry) GetRouteActionPlugins() []plugins.RouteActionPlugin {
 func (p *pluginRegistry) GetWeightedDestinationPlugins() []plugins.WeightedDestinationPlugin {
 	return p.weightedDestinationPlugins
 }
+
+// GetGeneratedResourceValidatorPlugins returns the plugins that were registered which validate generated resources.
+func (p *pluginRegistry) GetGeneratedResourceValidatorPlugins() []plugins.GeneratedResourceValidatorPlugin {
+	return p.generatedResourceValidators
+}
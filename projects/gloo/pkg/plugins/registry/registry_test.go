@@ -32,6 +32,7 @@ import (
 
 	"github.com/solo-io/gloo/projects/gloo/pkg/bootstrap"
 	"github.com/solo-io/gloo/projects/gloo/pkg/plugins"
+	"github.com/solo-io/gloo/projects/gloo/pkg/plugins/tunneling"
 	"github.com/solo-io/solo-kit/pkg/api/v1/resources/core"
 )
 
@@ -48,6 +49,17 @@ func TestPlugins(t *testing.T) {
 	}
 }
 
+func TestGeneratedResourceValidatorPlugins(t *testing.T) {
+	pluginRegistry := NewPluginRegistry(Plugins(bootstrap.Opts{}))
+	var validatorNames []string
+	for _, plugin := range pluginRegistry.GetGeneratedResourceValidatorPlugins() {
+		validatorNames = append(validatorNames, plugin.Name())
+	}
+	if len(validatorNames) != 1 || validatorNames[0] != tunneling.ExtensionName {
+		t.Errorf("Expected only the tunneling plugin to validate generated resources, found %v", validatorNames)
+	}
+}
+
 func TestPluginsHttpFilterUsefulness(t *testing.T) {
 	opts := bootstrap.Opts{}
 	pluginRegistryFactory := GetPluginRegistryFactory(opts)
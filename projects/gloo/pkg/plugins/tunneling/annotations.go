@@ -0,0 +1,232 @@
+package tunneling
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	envoy_config_cluster_v3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	envoytcp "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/tcp_proxy/v3"
+	envoyauth "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	"github.com/golang/protobuf/ptypes/duration"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"github.com/rotisserie/eris"
+	v1 "github.com/solo-io/gloo/projects/gloo/pkg/api/v1"
+	"github.com/solo-io/gloo/projects/gloo/pkg/translator"
+)
+
+var (
+	ProxyServiceNotFoundError = func(us *v1.Upstream, svc *proxyServiceRef) error {
+		return eris.Errorf("tunneling upstream %s references HTTP CONNECT proxy service %s which was not found", us.GetMetadata().Ref().Key(), svc)
+	}
+	HappyEyeballsClusterTypeError = func(us *v1.Upstream, clusterType fmt.Stringer) error {
+		return eris.Errorf("happy eyeballs cannot be enabled on tunneling upstream %s: requires a LOGICAL_DNS cluster, found %s cluster", us.GetMetadata().Ref().Key(), clusterType)
+	}
+	InvalidVerifySubjectAltNameError = func(us *v1.Upstream, san string, reason string) error {
+		return eris.Errorf("invalid HTTP CONNECT proxy subject alt name %q on tunneling upstream %s: %s", san, us.GetMetadata().Ref().Key(), reason)
+	}
+	HttpConnectSslConfigError = func(us *v1.Upstream, err error) error {
+		return eris.Wrapf(err, "failed to resolve the HTTP CONNECT TLS configuration of tunneling upstream %s", us.GetMetadata().Ref().Key())
+	}
+	PipePathCollisionError = func(pipe, upstream, otherUpstream string) error {
+		return eris.Errorf("tunneling upstreams %s and %s would share the pipe %s", otherUpstream, upstream, pipe)
+	}
+	MissingConnectAuthorityError = func(us *v1.Upstream) error {
+		return eris.Errorf("tunneling upstream %s sets the %s annotation but no httpProxyHostname to CONNECT to", us.GetMetadata().Ref().Key(), ProxyServiceAnnotation)
+	}
+	UpstreamPipePathTooLongError = func(us *v1.Upstream, pipe string) error {
+		return eris.Wrapf(PipePathTooLongError(pipe), "cannot tunnel upstream %s", us.GetMetadata().Ref().Key())
+	}
+	ProxyServiceClusterSettingError = func(us *v1.Upstream, setting string) error {
+		return eris.Errorf("tunneling upstream %s cannot combine the %s annotation with %s: the cluster of the proxy service is shared, and is not modified",
+			us.GetMetadata().Ref().Key(), ProxyServiceAnnotation, setting)
+	}
+)
+
+// tunnelingConfig holds the resolved tunnel settings of an upstream
+type tunnelingConfig struct {
+	maxSessionKeys *wrappers.UInt32Value
+	proxyService   *proxyServiceRef
+	edsSelfCluster bool
+	happyEyeballs  bool
+	tenant         string
+	// flushes of the access logs of the generated TCP proxy, nil to only log when connections close
+	accessLogFlushInterval *duration.Duration
+	// the protocol used to reach the HTTP CONNECT proxy is negotiated over ALPN
+	httpProtocolAutoDetect bool
+	// the generated self cluster uses a connection pool per downstream connection
+	connectionPoolPerDownstream bool
+}
+
+// proxyServiceRef references the kubernetes service of an HTTP CONNECT proxy
+type proxyServiceRef struct {
+	namespace string
+	name      string
+	port      uint32
+}
+
+func (r *proxyServiceRef) String() string {
+	return fmt.Sprintf("%s/%s:%d", r.namespace, r.name, r.port)
+}
+
+func parseProxyServiceRef(val string) (*proxyServiceRef, error) {
+	nsName, port, ok := strings.Cut(val, ":")
+	if !ok {
+		return nil, eris.Errorf("expected <namespace>/<name>:<port>, got %q", val)
+	}
+	namespace, name, ok := strings.Cut(nsName, "/")
+	if !ok || namespace == "" || name == "" {
+		return nil, eris.Errorf("expected <namespace>/<name>:<port>, got %q", val)
+	}
+	portNum, err := strconv.ParseUint(port, 10, 16)
+	if err != nil || portNum == 0 {
+		return nil, eris.Errorf("invalid port in %q", val)
+	}
+	return &proxyServiceRef{
+		namespace: namespace,
+		name:      name,
+		port:      uint32(portNum),
+	}, nil
+}
+
+// isTunnelingUpstream returns true if the upstream configures an HTTP CONNECT proxy to tunnel through
+func isTunnelingUpstream(us *v1.Upstream) bool {
+	if us.GetHttpProxyHostname().GetValue() != "" {
+		return true
+	}
+	_, ok := us.GetMetadata().GetAnnotations()[ProxyServiceAnnotation]
+	return ok
+}
+
+// tunnelingConfigForUpstream resolves the tunnel settings of the upstream, see ExperimentalConfig
+func tunnelingConfigForUpstream(defaults ExperimentalConfig, settings *v1.Settings, us *v1.Upstream) (*tunnelingConfig, error) {
+	if err := defaults.validateTunnelDefaults(); err != nil {
+		return nil, err
+	}
+	merged, err := experimentalConfigForUpstream(defaults, settings, us)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &tunnelingConfig{
+		maxSessionKeys:              merged.MaxSessionKeys,
+		edsSelfCluster:              merged.SelfClusterType == selfClusterTypeEds,
+		happyEyeballs:               merged.HappyEyeballs.GetValue(),
+		tenant:                      merged.Tenant,
+		accessLogFlushInterval:      merged.AccessLogFlushInterval,
+		httpProtocolAutoDetect:      merged.HttpProtocolAutoDetect.GetValue(),
+		connectionPoolPerDownstream: merged.ConnectionPoolPerDownstream.GetValue(),
+	}
+	if merged.ProxyService != "" {
+		if us.GetHttpProxyHostname().GetValue() == "" {
+			return nil, MissingConnectAuthorityError(us)
+		}
+		// validated along with the setting
+		cfg.proxyService, _ = parseProxyServiceRef(merged.ProxyService)
+	}
+
+	if err := validateVerifySubjectAltNames(us); err != nil {
+		return nil, err
+	}
+	if err := validateHttpConnectProtocol(us, cfg.httpProtocolAutoDetect); err != nil {
+		return nil, err
+	}
+	if err := cfg.validateProxyService(us); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// the settings of the connection to the HTTP CONNECT proxy are applied to the cluster reaching it. A proxy service is
+// reached through the cluster of its own upstream, which other routes may target, so such settings are rejected.
+func (c *tunnelingConfig) validateProxyService(us *v1.Upstream) error {
+	if c.proxyService == nil {
+		return nil
+	}
+	switch {
+	case us.GetHttpConnectSslConfig() != nil:
+		return ProxyServiceClusterSettingError(us, "httpConnectSslConfig")
+	case c.happyEyeballs:
+		return ProxyServiceClusterSettingError(us, "the "+HappyEyeballsAnnotation+" annotation")
+	case c.httpProtocolAutoDetect:
+		return ProxyServiceClusterSettingError(us, "the "+HttpProtocolAutoDetectAnnotation+" annotation")
+	}
+	return nil
+}
+
+// the SANs expected on the certificate of the HTTP CONNECT proxy are matched exactly by envoy, so entries which can
+// never match are rejected rather than silently making the proxy unreachable
+func validateVerifySubjectAltNames(us *v1.Upstream) error {
+	seen := map[string]bool{}
+	for _, san := range us.GetHttpConnectSslConfig().GetVerifySubjectAltName() {
+		switch {
+		case san == "":
+			return InvalidVerifySubjectAltNameError(us, san, "must not be empty")
+		case strings.IndexFunc(san, unicode.IsSpace) >= 0:
+			return InvalidVerifySubjectAltNameError(us, san, "must not contain whitespace")
+		case seen[san]:
+			return InvalidVerifySubjectAltNameError(us, san, "duplicate entry")
+		}
+		seen[san] = true
+	}
+	return nil
+}
+
+// proxyCluster returns the cluster the generated forwarding listener reaches the HTTP CONNECT proxy through: the
+// cluster of the upstream itself, whose hosts are the proxy, unless it references a proxy service, which must then
+// resolve to a kubernetes upstream in the snapshot.
+func (c *tunnelingConfig) proxyCluster(us *v1.Upstream, upstreams v1.UpstreamList) (string, error) {
+	if c.proxyService == nil {
+		return translator.UpstreamToClusterName(us.GetMetadata().Ref()), nil
+	}
+	for _, candidate := range upstreams {
+		kubeSpec := candidate.GetKube()
+		if kubeSpec.GetServiceNamespace() == c.proxyService.namespace &&
+			kubeSpec.GetServiceName() == c.proxyService.name &&
+			kubeSpec.GetServicePort() == c.proxyService.port {
+			return translator.UpstreamToClusterName(candidate.GetMetadata().Ref()), nil
+		}
+	}
+	return "", ProxyServiceNotFoundError(us, c.proxyService)
+}
+
+// the in-memory pipe used to reach the forwarding listener generated for the cluster (only works on linux)
+func (c *tunnelingConfig) pipePath(cluster string) string {
+	if c.tenant == "" {
+		return "@/" + cluster
+	}
+	return "@/" + c.tenant + "/" + cluster
+}
+
+// validatedPipePath is pipePath, erroring if envoy would reject the pipe, e.g. when a tenant makes the pipe of a long
+// cluster name too long
+func (c *tunnelingConfig) validatedPipePath(us *v1.Upstream, cluster string) (string, error) {
+	pipe := c.pipePath(cluster)
+	if len(pipe) > maxPipePathLength {
+		return "", UpstreamPipePathTooLongError(us, pipe)
+	}
+	return pipe, nil
+}
+
+// applies the tunneling settings to the TcpProxy of the generated forwarding listener
+func (c *tunnelingConfig) applyToTcpProxy(tcpProxy *envoytcp.TcpProxy) {
+	if c.accessLogFlushInterval != nil {
+		tcpProxy.AccessLogFlushInterval = c.accessLogFlushInterval
+	}
+}
+
+// applies the tunneling settings to the generated self cluster
+func (c *tunnelingConfig) applyToSelfCluster(selfCluster *envoy_config_cluster_v3.Cluster) {
+	if c.connectionPoolPerDownstream {
+		selfCluster.ConnectionPoolPerDownstreamConnection = true
+	}
+}
+
+// applies the tunneling settings to an UpstreamTlsContext originated by the generated resources
+func (c *tunnelingConfig) applyToUpstreamTlsContext(tlsContext *envoyauth.UpstreamTlsContext) {
+	if c.maxSessionKeys != nil {
+		tlsContext.MaxSessionKeys = c.maxSessionKeys
+	}
+}
@@ -0,0 +1,83 @@
+package tunneling
+
+import (
+	"github.com/golang/protobuf/ptypes/duration"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	v1 "github.com/solo-io/gloo/projects/gloo/pkg/api/v1"
+	"github.com/solo-io/gloo/projects/gloo/pkg/translator"
+)
+
+// EffectiveConfig is the tunneling configuration of an upstream once all of its sources are merged: the defaults set on
+// the plugin, the tunneling annotations of the Settings, the upstream spec and the tunneling annotations of the
+// upstream, in order of precedence, see ExperimentalConfig.
+// It describes what the generated resources are made of, so that it can be logged and previewed.
+type EffectiveConfig struct {
+	// the authority of the CONNECT requests, from the httpProxyHostname of the upstream
+	ConnectHostname string
+	// the cluster the HTTP CONNECT proxy is reached through: the cluster of the upstream, or of its proxy service
+	ProxyCluster string
+	// the headers sent with the CONNECT requests
+	ConnectHeaders []*v1.HeaderValue
+	// whether TLS is originated to the HTTP CONNECT proxy
+	ConnectTls bool
+	// the number of TLS session keys cached per cluster, nil when envoy's default applies
+	MaxSessionKeys *wrappers.UInt32Value
+	// how the endpoints of the generated self cluster are delivered: "static" or "eds"
+	SelfClusterType string
+	// whether happy eyeballs is used to connect to the HTTP CONNECT proxy
+	HappyEyeballs bool
+	// the tenant the generated forwarding listener is scoped to, if any
+	Tenant string
+	// the in-memory pipe of the generated forwarding listener
+	PipePath string
+	// the interval at which the generated TCP proxy flushes its access logs, nil when only flushed on close
+	AccessLogFlushInterval *duration.Duration
+	// whether the protocol used to reach the HTTP CONNECT proxy is negotiated over ALPN
+	HttpProtocolAutoDetect bool
+	// whether the generated self cluster uses a connection pool per downstream connection
+	ConnectionPoolPerDownstream bool
+}
+
+// EffectiveTunnelingConfig returns the effective tunneling configuration of the upstream, resolving its proxy service
+// reference against the given upstreams, as the plugin does with the given Settings and experimental configuration.
+// It returns nil if the upstream does not tunnel.
+func EffectiveTunnelingConfig(us *v1.Upstream, upstreams v1.UpstreamList, settings *v1.Settings, experimental ExperimentalConfig) (*EffectiveConfig, error) {
+	if !isTunnelingUpstream(us) {
+		return nil, nil
+	}
+	cfg, err := tunnelingConfigForUpstream(experimental, settings, us)
+	if err != nil {
+		return nil, err
+	}
+	proxyCluster, err := cfg.proxyCluster(us, upstreams)
+	if err != nil {
+		return nil, err
+	}
+	connectHostname, err := normalizeConnectHostname(us, us.GetHttpProxyHostname().GetValue(), experimental.ConnectHostname)
+	if err != nil {
+		return nil, err
+	}
+	pipePath, err := cfg.validatedPipePath(us, translator.UpstreamToClusterName(us.GetMetadata().Ref()))
+	if err != nil {
+		return nil, err
+	}
+
+	selfClusterType := selfClusterTypeStatic
+	if cfg.edsSelfCluster {
+		selfClusterType = selfClusterTypeEds
+	}
+	return &EffectiveConfig{
+		ConnectHostname:             connectHostname,
+		ProxyCluster:                proxyCluster,
+		ConnectHeaders:              us.GetHttpConnectHeaders(),
+		ConnectTls:                  us.GetHttpConnectSslConfig() != nil,
+		MaxSessionKeys:              cfg.maxSessionKeys,
+		SelfClusterType:             selfClusterType,
+		HappyEyeballs:               cfg.happyEyeballs,
+		Tenant:                      cfg.tenant,
+		PipePath:                    pipePath,
+		AccessLogFlushInterval:      cfg.accessLogFlushInterval,
+		HttpProtocolAutoDetect:      cfg.httpProtocolAutoDetect,
+		ConnectionPoolPerDownstream: cfg.connectionPoolPerDownstream,
+	}, nil
+}
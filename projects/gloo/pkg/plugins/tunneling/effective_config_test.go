@@ -0,0 +1,237 @@
+package tunneling_test
+
+import (
+	"strings"
+
+	"github.com/golang/protobuf/ptypes/duration"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "github.com/solo-io/gloo/projects/gloo/pkg/api/v1"
+	"github.com/solo-io/gloo/projects/gloo/pkg/api/v1/options/kubernetes"
+	"github.com/solo-io/gloo/projects/gloo/pkg/plugins/tunneling"
+	"github.com/solo-io/skv2/test/matchers"
+	"github.com/solo-io/solo-kit/pkg/api/v1/resources/core"
+)
+
+var _ = Describe("EffectiveTunnelingConfig", func() {
+
+	var (
+		us        *v1.Upstream
+		proxyUs   *v1.Upstream
+		upstreams v1.UpstreamList
+	)
+
+	BeforeEach(func() {
+		us = &v1.Upstream{
+			Metadata: &core.Metadata{
+				Name:      "http-proxy-upstream",
+				Namespace: "gloo-system",
+			},
+			HttpProxyHostname: &wrappers.StringValue{Value: httpProxyHostname},
+		}
+		proxyUs = &v1.Upstream{
+			Metadata: &core.Metadata{
+				Name:      "proxy-3128",
+				Namespace: "gloo-system",
+			},
+			UpstreamType: &v1.Upstream_Kube{
+				Kube: &kubernetes.UpstreamSpec{
+					ServiceName:      "proxy",
+					ServiceNamespace: "egress",
+					ServicePort:      3128,
+				},
+			},
+		}
+		upstreams = v1.UpstreamList{us, proxyUs}
+	})
+
+	It("should return nil for upstreams which do not tunnel", func() {
+		cfg, err := tunneling.EffectiveTunnelingConfig(proxyUs, upstreams, nil, tunneling.ExperimentalConfig{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cfg).To(BeNil())
+	})
+
+	It("should apply the defaults when the upstream only sets its hostname", func() {
+		cfg, err := tunneling.EffectiveTunnelingConfig(us, upstreams, nil, tunneling.ExperimentalConfig{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cfg.ConnectHostname).To(Equal(httpProxyHostname))
+		Expect(cfg.ProxyCluster).To(Equal("http-proxy-upstream_gloo-system"))
+		Expect(cfg.ConnectHeaders).To(BeEmpty())
+		Expect(cfg.ConnectTls).To(BeFalse())
+		Expect(cfg.MaxSessionKeys).To(BeNil())
+		Expect(cfg.SelfClusterType).To(Equal("static"))
+		Expect(cfg.HappyEyeballs).To(BeFalse())
+		Expect(cfg.Tenant).To(BeEmpty())
+		Expect(cfg.PipePath).To(Equal("@/http-proxy-upstream_gloo-system"))
+		Expect(cfg.AccessLogFlushInterval).To(BeNil())
+		Expect(cfg.HttpProtocolAutoDetect).To(BeFalse())
+		Expect(cfg.ConnectionPoolPerDownstream).To(BeFalse())
+	})
+
+	It("should merge the upstream spec and annotations over the defaults", func() {
+		us.HttpConnectHeaders = []*v1.HeaderValue{{Key: "Proxy-Authorization", Value: "Basic"}}
+		us.HttpConnectSslConfig = &v1.UpstreamSslConfig{Sni: "host.com"}
+		us.Metadata.Annotations = map[string]string{
+			tunneling.MaxSessionKeysAnnotation:         "0",
+			tunneling.SelfClusterTypeAnnotation:        "eds",
+			tunneling.HappyEyeballsAnnotation:          "true",
+			tunneling.TenantAnnotation:                 "tenant-a",
+			tunneling.AccessLogFlushIntervalAnnotation: "30s",
+		}
+
+		cfg, err := tunneling.EffectiveTunnelingConfig(us, upstreams, nil, tunneling.ExperimentalConfig{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cfg.ConnectHostname).To(Equal(httpProxyHostname))
+		Expect(cfg.ConnectHeaders).To(HaveLen(1))
+		Expect(cfg.ConnectHeaders[0]).To(matchers.MatchProto(&v1.HeaderValue{Key: "Proxy-Authorization", Value: "Basic"}))
+		Expect(cfg.ConnectTls).To(BeTrue())
+		Expect(cfg.MaxSessionKeys).To(matchers.MatchProto(&wrappers.UInt32Value{Value: 0}))
+		Expect(cfg.SelfClusterType).To(Equal("eds"))
+		Expect(cfg.HappyEyeballs).To(BeTrue())
+		Expect(cfg.Tenant).To(Equal("tenant-a"))
+		Expect(cfg.PipePath).To(Equal("@/tenant-a/http-proxy-upstream_gloo-system"))
+		Expect(cfg.AccessLogFlushInterval).To(matchers.MatchProto(&duration.Duration{Seconds: 30}))
+	})
+
+	It("should reach the proxy through the cluster of the proxy service reference", func() {
+		us.Metadata.Annotations = map[string]string{tunneling.ProxyServiceAnnotation: "egress/proxy:3128"}
+
+		cfg, err := tunneling.EffectiveTunnelingConfig(us, upstreams, nil, tunneling.ExperimentalConfig{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cfg.ProxyCluster).To(Equal("proxy-3128_gloo-system"))
+		Expect(cfg.ConnectHostname).To(Equal(httpProxyHostname))
+	})
+
+	It("should apply the hostname case option", func() {
+		us.HttpProxyHostname = &wrappers.StringValue{Value: "Proxy.Corp.COM:3128"}
+
+		cfg, err := tunneling.EffectiveTunnelingConfig(us, upstreams, nil, tunneling.ExperimentalConfig{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cfg.ConnectHostname).To(Equal("proxy.corp.com:3128"))
+
+		cfg, err = tunneling.EffectiveTunnelingConfig(us, upstreams, nil, tunneling.ExperimentalConfig{ConnectHostname: tunneling.ConnectHostnameOptions{PreserveCase: true}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cfg.ConnectHostname).To(Equal("Proxy.Corp.COM:3128"))
+	})
+
+	It("should only strip the prefix from the exact bytes matching it", func() {
+		// the Kelvin sign lowercases to "k", but is 3 bytes long
+		us.HttpProxyHostname = &wrappers.StringValue{Value: "\u212a-proxy.corp.com:3128"}
+
+		cfg, err := tunneling.EffectiveTunnelingConfig(us, upstreams, nil, tunneling.ExperimentalConfig{ConnectHostname: tunneling.ConnectHostnameOptions{PrefixToStrip: "k-", PreserveCase: true}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cfg.ConnectHostname).To(Equal("\u212a-proxy.corp.com:3128"))
+	})
+
+	It("should accept CONNECT hosts which are not DNS-1123 names", func() {
+		us.HttpProxyHostname = &wrappers.StringValue{Value: "proxy_corp.com.:3128"}
+
+		cfg, err := tunneling.EffectiveTunnelingConfig(us, upstreams, nil, tunneling.ExperimentalConfig{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cfg.ConnectHostname).To(Equal("proxy_corp.com.:3128"))
+	})
+
+	It("should strip the configured prefix from the CONNECT hostname", func() {
+		us.HttpProxyHostname = &wrappers.StringValue{Value: "http://proxy.corp.com:3128"}
+
+		cfg, err := tunneling.EffectiveTunnelingConfig(us, upstreams, nil, tunneling.ExperimentalConfig{ConnectHostname: tunneling.ConnectHostnameOptions{PrefixToStrip: "http://"}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cfg.ConnectHostname).To(Equal("proxy.corp.com:3128"))
+	})
+
+	It("should error when the tenant makes the pipe too long", func() {
+		us.Metadata.Name = strings.Repeat("a", 90)
+		us.Metadata.Annotations = map[string]string{tunneling.TenantAnnotation: "tenant-a"}
+
+		_, err := tunneling.EffectiveTunnelingConfig(us, upstreams, nil, tunneling.ExperimentalConfig{})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("is 113 characters long, must be at most 107"))
+	})
+
+	Context("precedence", func() {
+
+		var (
+			settings *v1.Settings
+			defaults tunneling.ExperimentalConfig
+		)
+
+		BeforeEach(func() {
+			settings = &v1.Settings{
+				Metadata: &core.Metadata{
+					Name:      "default",
+					Namespace: "gloo-system",
+				},
+			}
+			defaults = tunneling.ExperimentalConfig{
+				Tenant:                 "plugin",
+				AccessLogFlushInterval: &duration.Duration{Seconds: 10},
+				HttpProtocolAutoDetect: &wrappers.BoolValue{Value: true},
+			}
+			// auto-detection negotiates the protocol over ALPN
+			us.HttpConnectSslConfig = &v1.UpstreamSslConfig{AlpnProtocols: []string{"h2", "http/1.1"}}
+		})
+
+		It("should apply the defaults set on the plugin", func() {
+			cfg, err := tunneling.EffectiveTunnelingConfig(us, upstreams, settings, defaults)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cfg.Tenant).To(Equal("plugin"))
+			Expect(cfg.AccessLogFlushInterval).To(matchers.MatchProto(&duration.Duration{Seconds: 10}))
+			Expect(cfg.HttpProtocolAutoDetect).To(BeTrue())
+		})
+
+		It("should apply the annotations of the settings over the defaults set on the plugin", func() {
+			settings.Metadata.Annotations = map[string]string{tunneling.TenantAnnotation: "settings"}
+
+			cfg, err := tunneling.EffectiveTunnelingConfig(us, upstreams, settings, defaults)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cfg.Tenant).To(Equal("settings"))
+			Expect(cfg.PipePath).To(Equal("@/settings/http-proxy-upstream_gloo-system"))
+			Expect(cfg.AccessLogFlushInterval).To(matchers.MatchProto(&duration.Duration{Seconds: 10}))
+		})
+
+		It("should apply the upstream spec over the annotations of the settings", func() {
+			settings.Metadata.Annotations = map[string]string{tunneling.HttpProtocolAutoDetectAnnotation: "true"}
+			us.UseHttp2 = &wrappers.BoolValue{Value: false}
+
+			cfg, err := tunneling.EffectiveTunnelingConfig(us, upstreams, settings, defaults)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cfg.HttpProtocolAutoDetect).To(BeFalse())
+		})
+
+		It("should apply the annotations of the upstream over every other source", func() {
+			settings.Metadata.Annotations = map[string]string{
+				tunneling.TenantAnnotation:                 "settings",
+				tunneling.HttpProtocolAutoDetectAnnotation: "false",
+			}
+			us.UseHttp2 = &wrappers.BoolValue{Value: false}
+			us.Metadata.Annotations = map[string]string{
+				tunneling.TenantAnnotation:                 "upstream",
+				tunneling.HttpProtocolAutoDetectAnnotation: "true",
+			}
+
+			cfg, err := tunneling.EffectiveTunnelingConfig(us, upstreams, settings, defaults)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cfg.Tenant).To(Equal("upstream"))
+			Expect(cfg.AccessLogFlushInterval).To(matchers.MatchProto(&duration.Duration{Seconds: 10}))
+			Expect(cfg.HttpProtocolAutoDetect).To(BeTrue())
+		})
+
+		It("should error on an invalid annotation of the settings", func() {
+			settings.Metadata.Annotations = map[string]string{tunneling.TenantAnnotation: "Tenant_A"}
+
+			_, err := tunneling.EffectiveTunnelingConfig(us, upstreams, settings, defaults)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("invalid value for annotation %s on settings gloo-system.default", tunneling.TenantAnnotation))
+		})
+	})
+
+	It("should error when a proxy service reference has no CONNECT authority", func() {
+		us.HttpProxyHostname = nil
+		us.Metadata.Annotations = map[string]string{tunneling.ProxyServiceAnnotation: "egress/proxy:3128"}
+
+		_, err := tunneling.EffectiveTunnelingConfig(us, upstreams, nil, tunneling.ExperimentalConfig{})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("sets the %s annotation but no httpProxyHostname", tunneling.ProxyServiceAnnotation))
+	})
+})
@@ -0,0 +1,150 @@
+package tunneling
+
+import (
+	"sort"
+	"strings"
+
+	envoy_config_cluster_v3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_config_endpoint_v3 "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	envoy_config_listener_v3 "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	"github.com/hashicorp/go-multierror"
+	"github.com/rotisserie/eris"
+	envoycache "github.com/solo-io/solo-kit/pkg/api/v1/control-plane/cache"
+	"github.com/solo-io/solo-kit/pkg/api/v1/control-plane/types"
+)
+
+// envoy rejects pipe paths which do not fit in sockaddr_un.sun_path (108 bytes, including the terminating null byte)
+const maxPipePathLength = 107
+
+var (
+	WouldBeNackedError = func(kind, name string, err error) error {
+		return eris.Wrapf(err, "generated tunneling %s %s would be rejected by envoy", kind, name)
+	}
+	PipePathTooLongError = func(path string) error {
+		return eris.Errorf("pipe path %s is %d characters long, must be at most %d", path, len(path), maxPipePathLength)
+	}
+)
+
+type validatable interface {
+	Validate() error
+}
+
+// ValidateGeneratedResources reports the tunneling resources in a translated snapshot which envoy would reject
+func (p *plugin) ValidateGeneratedResources(snap envoycache.Snapshot) error {
+	return ValidateGeneratedResources(snap)
+}
+
+// ValidateGeneratedResources simulates envoy's validation of the tunneling resources in an xds snapshot, so that
+// updates envoy would NACK can be reported before they are sent to it.
+// This covers the constraints declared on the envoy protos (required fields, value ranges) as well as the length
+// of the pipe paths, which are derived from the names of the tunneling clusters.
+func ValidateGeneratedResources(snap envoycache.Snapshot) error {
+	var (
+		clusters  []*envoy_config_cluster_v3.Cluster
+		endpoints []*envoy_config_endpoint_v3.ClusterLoadAssignment
+		listeners []*envoy_config_listener_v3.Listener
+	)
+	for _, res := range sortedResources(snap, types.ClusterTypeV3, SelfClusterPrefix) {
+		if cluster, ok := res.(*envoy_config_cluster_v3.Cluster); ok {
+			clusters = append(clusters, cluster)
+		}
+	}
+	for _, res := range sortedResources(snap, types.EndpointTypeV3, SelfClusterPrefix) {
+		if cla, ok := res.(*envoy_config_endpoint_v3.ClusterLoadAssignment); ok {
+			endpoints = append(endpoints, cla)
+		}
+	}
+	for _, res := range sortedResources(snap, types.ListenerTypeV3, SelfListenerPrefix) {
+		if listener, ok := res.(*envoy_config_listener_v3.Listener); ok {
+			listeners = append(listeners, listener)
+		}
+	}
+	return validateResources(clusters, endpoints, listeners)
+}
+
+// ValidateTunnelingResources validates freshly generated tunneling resources the way envoy would, against the
+// constraints declared on the envoy protos and the length of the pipe paths, so that a generation bug surfaces as a
+// translation error rather than as an opaque NACK.
+func ValidateTunnelingResources(generated *TunnelingResources) error {
+	return validateResources(generated.Clusters, generated.Endpoints, generated.Listeners)
+}
+
+// reports every tunneling resource envoy would reject, whether it comes from an xds snapshot or was just generated
+func validateResources(clusters []*envoy_config_cluster_v3.Cluster, endpoints []*envoy_config_endpoint_v3.ClusterLoadAssignment,
+	listeners []*envoy_config_listener_v3.Listener) error {
+	var multiErr *multierror.Error
+	for _, cluster := range clusters {
+		if err := validateCluster(cluster); err != nil {
+			multiErr = multierror.Append(multiErr, WouldBeNackedError("cluster", cluster.GetName(), err))
+		}
+	}
+	for _, cla := range endpoints {
+		if err := validateLoadAssignment(cla); err != nil {
+			multiErr = multierror.Append(multiErr, WouldBeNackedError("endpoints", cla.GetClusterName(), err))
+		}
+	}
+	for _, listener := range listeners {
+		if err := validateListener(listener); err != nil {
+			multiErr = multierror.Append(multiErr, WouldBeNackedError("listener", listener.GetName(), err))
+		}
+	}
+	return multiErr.ErrorOrNil()
+}
+
+func validateCluster(cluster *envoy_config_cluster_v3.Cluster) error {
+	if err := cluster.Validate(); err != nil {
+		return err
+	}
+	return validateLoadAssignment(cluster.GetLoadAssignment())
+}
+
+func validateLoadAssignment(cla *envoy_config_endpoint_v3.ClusterLoadAssignment) error {
+	if cla == nil {
+		return nil
+	}
+	if err := cla.Validate(); err != nil {
+		return err
+	}
+	for _, localityEndpoints := range cla.GetEndpoints() {
+		for _, lbEndpoint := range localityEndpoints.GetLbEndpoints() {
+			if err := validatePipe(lbEndpoint.GetEndpoint().GetAddress()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func validateListener(listener *envoy_config_listener_v3.Listener) error {
+	if err := listener.Validate(); err != nil {
+		return err
+	}
+	return validatePipe(listener.GetAddress())
+}
+
+func validatePipe(address *envoy_config_core_v3.Address) error {
+	if path := address.GetPipe().GetPath(); len(path) > maxPipePathLength {
+		return PipePathTooLongError(path)
+	}
+	return nil
+}
+
+// the resources of the given type whose name has the given prefix, sorted by name for stable reporting
+func sortedResources(snap envoycache.Snapshot, typeUrl, namePrefix string) []validatable {
+	items := snap.GetResources(typeUrl).Items
+	var names []string
+	for name := range items {
+		if strings.HasPrefix(name, namePrefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	var resources []validatable
+	for _, name := range names {
+		if res, ok := items[name].ResourceProto().(validatable); ok {
+			resources = append(resources, res)
+		}
+	}
+	return resources
+}
@@ -0,0 +1,288 @@
+package tunneling
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/duration"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"github.com/rotisserie/eris"
+	v1 "github.com/solo-io/gloo/projects/gloo/pkg/api/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// The environment variables setting the behavior of the plugin, see ExperimentalConfigFromEnv.
+const (
+	// set to "true" to emit trace spans around the phases of tunneling resource generation
+	TracingEnabledEnv = "TUNNELING_TRACING_ENABLED"
+	// set to "true" to log the CONNECT hostname of every tunneling upstream during translation
+	AuditLoggingEnabledEnv = "TUNNELING_AUDIT_LOGGING_ENABLED"
+	// set to "true" to fail translation when the tunneling configuration of an upstream cannot be resolved
+	StrictModeEnabledEnv = "TUNNELING_STRICT_MODE_ENABLED"
+	// set to "true" to send the CONNECT hostname of tunneling upstreams as configured, rather than lowercased
+	PreserveHostnameCaseEnv = "TUNNELING_PRESERVE_HOSTNAME_CASE"
+	// set to a prefix, e.g. "http://", to strip it from the CONNECT hostname of tunneling upstreams
+	HostnamePrefixToStripEnv = "TUNNELING_HOSTNAME_PREFIX_TO_STRIP"
+	// set to "true" to validate the generated tunneling resources against the envoy protos on every translation
+	GeneratedResourceValidationEnabledEnv = "TUNNELING_GENERATED_RESOURCE_VALIDATION_ENABLED"
+	// set to "true" to report tunneling upstreams which no route references
+	UnreferencedUpstreamWarningsEnabledEnv = "TUNNELING_UNREFERENCED_UPSTREAM_WARNINGS_ENABLED"
+)
+
+// The annotations of tunneling upstreams setting their tunnels, see ExperimentalConfig.
+const (
+	// MaxSessionKeysAnnotation controls TLS session resumption on the connections originated by a tunneling upstream.
+	// The value is the maximum number of session keys envoy caches per cluster; "0" disables session resumption.
+	MaxSessionKeysAnnotation = "gloo.solo.io/tunneling_max_session_keys"
+
+	// ProxyServiceAnnotation references the kubernetes service of the HTTP CONNECT proxy, as "<namespace>/<name>:<port>".
+	// The generated forwarding listener reaches the proxy through the cluster of the kubernetes upstream of the service,
+	// rather than through the hosts of the tunneling upstream; the service must be known to gloo as a kubernetes
+	// upstream. The httpProxyHostname of the tunneling upstream remains the authority of the CONNECT requests.
+	ProxyServiceAnnotation = "gloo.solo.io/tunneling_proxy_service"
+
+	// SelfClusterTypeAnnotation selects how the endpoints of the generated self cluster are delivered to envoy:
+	// either inline in the cluster ("static", the default) or over EDS ("eds").
+	SelfClusterTypeAnnotation = "gloo.solo.io/tunneling_self_cluster_type"
+
+	// HappyEyeballsAnnotation enables happy eyeballs ("true") when connecting to the HTTP CONNECT proxy, by resolving
+	// both IPv4 and IPv6 addresses for it. Only applies to upstreams whose proxy address is resolved over logical DNS.
+	HappyEyeballsAnnotation = "gloo.solo.io/tunneling_happy_eyeballs"
+
+	// TenantAnnotation scopes the in-memory pipe of the generated forwarding listener to a tenant, as "@/<tenant>/<cluster>",
+	// so that the generated listeners of different tenants never share an endpoint. The value must be a DNS-1123 label.
+	TenantAnnotation = "gloo.solo.io/tunneling_tenant"
+
+	// AccessLogFlushIntervalAnnotation sets the interval at which the generated TCP proxy flushes its access logs while
+	// a tunnel is open (e.g. "30s"), rather than only when the connection closes. Must be at least 1ms.
+	AccessLogFlushIntervalAnnotation = "gloo.solo.io/tunneling_access_log_flush_interval"
+
+	// HttpProtocolAutoDetectAnnotation lets envoy pick HTTP/2 or HTTP/1.1 ("true") to reach the HTTP CONNECT proxy, based
+	// on the protocol negotiated over ALPN, rather than the one selected by useHttp2. The httpConnectSslConfig of the
+	// upstream must then advertise both protocols, and useHttp2 must not be set.
+	HttpProtocolAutoDetectAnnotation = "gloo.solo.io/tunneling_http_protocol_auto_detect"
+
+	// ConnectionPoolPerDownstreamAnnotation isolates the connections of each downstream connection ("true"): the generated
+	// self cluster uses a dedicated connection pool per downstream connection, so that downstreams sharing a tunneling
+	// upstream never share an upstream connection.
+	ConnectionPoolPerDownstreamAnnotation = "gloo.solo.io/tunneling_connection_pool_per_downstream"
+
+	selfClusterTypeStatic = "static"
+	selfClusterTypeEds    = "eds"
+)
+
+var (
+	InvalidAnnotationError = func(us *v1.Upstream, annotation string, err error) error {
+		return eris.Wrapf(err, "invalid value for annotation %s on tunneling upstream %s", annotation, us.GetMetadata().Ref().Key())
+	}
+	InvalidSettingsAnnotationError = func(settings *v1.Settings, annotation string, err error) error {
+		return eris.Wrapf(err, "invalid value for annotation %s on settings %s", annotation, settings.GetMetadata().Ref().Key())
+	}
+	InvalidTunnelDefaultError = func(annotation string, err error) error {
+		return eris.Wrapf(err, "invalid default for annotation %s of tunneling upstreams", annotation)
+	}
+)
+
+// ExperimentalConfig is the tunneling configuration which is not part of the Upstream and Settings APIs.
+//
+// EXPERIMENTAL: the behavior of the plugin is set through the TUNNELING_* environment variables of gloo, and the
+// tunnels of upstreams through gloo.solo.io/tunneling_* annotations. Both may change or be removed in any release, as
+// these settings move to the Upstream and Settings protos.
+//
+// The settings of the tunnel of an upstream are, from lowest to highest precedence:
+//   - the defaults set on the plugin
+//   - the annotations of the Settings
+//   - the upstream spec, where it overlaps with these settings: an upstream selecting the protocol used to reach the
+//     HTTP CONNECT proxy with useHttp2 does not auto-detect it
+//   - the annotations of the upstream
+type ExperimentalConfig struct {
+	// TracingEnabledEnv
+	Tracing bool
+	// AuditLoggingEnabledEnv
+	AuditLogging bool
+	// StrictModeEnabledEnv
+	StrictMode bool
+	// PreserveHostnameCaseEnv and HostnamePrefixToStripEnv
+	ConnectHostname ConnectHostnameOptions
+	// GeneratedResourceValidationEnabledEnv
+	GeneratedResourceValidation bool
+	// UnreferencedUpstreamWarningsEnabledEnv
+	UnreferencedUpstreamWarnings bool
+
+	// The settings of the tunnels of upstreams; nil or empty when unset.
+
+	// MaxSessionKeysAnnotation
+	MaxSessionKeys *wrappers.UInt32Value
+	// ProxyServiceAnnotation, as "<namespace>/<name>:<port>"
+	ProxyService string
+	// SelfClusterTypeAnnotation, "static" or "eds"
+	SelfClusterType string
+	// HappyEyeballsAnnotation
+	HappyEyeballs *wrappers.BoolValue
+	// TenantAnnotation
+	Tenant string
+	// AccessLogFlushIntervalAnnotation
+	AccessLogFlushInterval *duration.Duration
+	// HttpProtocolAutoDetectAnnotation
+	HttpProtocolAutoDetect *wrappers.BoolValue
+	// ConnectionPoolPerDownstreamAnnotation
+	ConnectionPoolPerDownstream *wrappers.BoolValue
+}
+
+// ExperimentalConfigFromEnv returns the behavior of the plugin set through environment variables
+func ExperimentalConfigFromEnv() ExperimentalConfig {
+	return ExperimentalConfig{
+		Tracing:      isEnvTrue(TracingEnabledEnv),
+		AuditLogging: isEnvTrue(AuditLoggingEnabledEnv),
+		StrictMode:   isEnvTrue(StrictModeEnabledEnv),
+		ConnectHostname: ConnectHostnameOptions{
+			PrefixToStrip: os.Getenv(HostnamePrefixToStripEnv),
+			PreserveCase:  isEnvTrue(PreserveHostnameCaseEnv),
+		},
+		GeneratedResourceValidation:  isEnvTrue(GeneratedResourceValidationEnabledEnv),
+		UnreferencedUpstreamWarnings: isEnvTrue(UnreferencedUpstreamWarningsEnabledEnv),
+	}
+}
+
+func isEnvTrue(name string) bool {
+	return strings.ToLower(os.Getenv(name)) == "true"
+}
+
+// experimentalConfigForUpstream returns the tunnel settings of the upstream, merging the defaults set on the plugin,
+// the annotations of the settings, the upstream spec and the annotations of the upstream, in order of precedence
+func experimentalConfigForUpstream(defaults ExperimentalConfig, settings *v1.Settings, us *v1.Upstream) (ExperimentalConfig, error) {
+	cfg, err := defaults.withAnnotations(settings.GetMetadata().GetAnnotations(), func(annotation string, err error) error {
+		return InvalidSettingsAnnotationError(settings, annotation, err)
+	})
+	if err != nil {
+		return cfg, err
+	}
+	if us.GetUseHttp2() != nil {
+		cfg.HttpProtocolAutoDetect = &wrappers.BoolValue{Value: false}
+	}
+	return cfg.withAnnotations(us.GetMetadata().GetAnnotations(), func(annotation string, err error) error {
+		return InvalidAnnotationError(us, annotation, err)
+	})
+}
+
+// withAnnotations returns the tunnel settings set by the annotations over those of c, or the error built by invalid for
+// the first annotation whose value is invalid
+func (c ExperimentalConfig) withAnnotations(annotations map[string]string, invalid func(annotation string, err error) error) (ExperimentalConfig, error) {
+	cfg := c
+
+	if val, ok := annotations[MaxSessionKeysAnnotation]; ok {
+		maxSessionKeys, err := strconv.ParseUint(val, 10, 32)
+		if err != nil {
+			return cfg, invalid(MaxSessionKeysAnnotation, err)
+		}
+		cfg.MaxSessionKeys = &wrappers.UInt32Value{Value: uint32(maxSessionKeys)}
+	}
+
+	if val, ok := annotations[ProxyServiceAnnotation]; ok {
+		if _, err := parseProxyServiceRef(val); err != nil {
+			return cfg, invalid(ProxyServiceAnnotation, err)
+		}
+		cfg.ProxyService = val
+	}
+
+	if val, ok := annotations[SelfClusterTypeAnnotation]; ok {
+		if err := validateSelfClusterType(val); err != nil {
+			return cfg, invalid(SelfClusterTypeAnnotation, err)
+		}
+		cfg.SelfClusterType = val
+	}
+
+	if val, ok := annotations[HappyEyeballsAnnotation]; ok {
+		happyEyeballs, err := strconv.ParseBool(val)
+		if err != nil {
+			return cfg, invalid(HappyEyeballsAnnotation, err)
+		}
+		cfg.HappyEyeballs = &wrappers.BoolValue{Value: happyEyeballs}
+	}
+
+	if val, ok := annotations[TenantAnnotation]; ok {
+		if err := validateTenant(val); err != nil {
+			return cfg, invalid(TenantAnnotation, err)
+		}
+		cfg.Tenant = val
+	}
+
+	if val, ok := annotations[AccessLogFlushIntervalAnnotation]; ok {
+		interval, err := time.ParseDuration(val)
+		if err == nil {
+			err = validateAccessLogFlushInterval(interval)
+		}
+		if err != nil {
+			return cfg, invalid(AccessLogFlushIntervalAnnotation, err)
+		}
+		cfg.AccessLogFlushInterval = ptypes.DurationProto(interval)
+	}
+
+	if val, ok := annotations[HttpProtocolAutoDetectAnnotation]; ok {
+		autoDetect, err := strconv.ParseBool(val)
+		if err != nil {
+			return cfg, invalid(HttpProtocolAutoDetectAnnotation, err)
+		}
+		cfg.HttpProtocolAutoDetect = &wrappers.BoolValue{Value: autoDetect}
+	}
+
+	if val, ok := annotations[ConnectionPoolPerDownstreamAnnotation]; ok {
+		perDownstream, err := strconv.ParseBool(val)
+		if err != nil {
+			return cfg, invalid(ConnectionPoolPerDownstreamAnnotation, err)
+		}
+		cfg.ConnectionPoolPerDownstream = &wrappers.BoolValue{Value: perDownstream}
+	}
+
+	return cfg, nil
+}
+
+// the defaults of the tunnel settings are validated like the annotations overriding them
+func (c ExperimentalConfig) validateTunnelDefaults() error {
+	if c.ProxyService != "" {
+		if _, err := parseProxyServiceRef(c.ProxyService); err != nil {
+			return InvalidTunnelDefaultError(ProxyServiceAnnotation, err)
+		}
+	}
+	if c.SelfClusterType != "" {
+		if err := validateSelfClusterType(c.SelfClusterType); err != nil {
+			return InvalidTunnelDefaultError(SelfClusterTypeAnnotation, err)
+		}
+	}
+	if c.Tenant != "" {
+		if err := validateTenant(c.Tenant); err != nil {
+			return InvalidTunnelDefaultError(TenantAnnotation, err)
+		}
+	}
+	if c.AccessLogFlushInterval != nil {
+		if err := validateAccessLogFlushInterval(c.AccessLogFlushInterval.AsDuration()); err != nil {
+			return InvalidTunnelDefaultError(AccessLogFlushIntervalAnnotation, err)
+		}
+	}
+	return nil
+}
+
+func validateSelfClusterType(val string) error {
+	switch val {
+	case selfClusterTypeStatic, selfClusterTypeEds:
+		return nil
+	}
+	return eris.Errorf("expected %q or %q, got %q", selfClusterTypeStatic, selfClusterTypeEds, val)
+}
+
+func validateTenant(val string) error {
+	if errs := validation.IsDNS1123Label(val); len(errs) > 0 {
+		return eris.New(strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+func validateAccessLogFlushInterval(interval time.Duration) error {
+	if interval < time.Millisecond {
+		return eris.Errorf("must be at least 1ms, got %s", interval)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package tunneling
+
+import (
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/rotisserie/eris"
+	v1 "github.com/solo-io/gloo/projects/gloo/pkg/api/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+var (
+	InvalidConnectHostnameError = func(us *v1.Upstream, hostname string, reason string) error {
+		return eris.Errorf("invalid CONNECT hostname %q on tunneling upstream %s: %s", hostname, us.GetMetadata().Ref().Key(), reason)
+	}
+)
+
+// ConnectHostnameOptions configure how the CONNECT hostname of tunneling upstreams is normalized
+type ConnectHostnameOptions struct {
+	// stripped from the hostname, if present
+	PrefixToStrip string
+	// send the hostname with the case it is configured with, rather than lowercased
+	PreserveCase bool
+}
+
+// normalizeConnectHostname validates the authority of the HTTP CONNECT requests sent for the upstream, as
+// "<host>[:<port>]", once stripped of the configured prefix, and lowercases it unless its case is to be preserved.
+// host names are case-insensitive, but some HTTP CONNECT proxies compare the authority of CONNECT requests verbatim.
+// The host itself is checked separately by validateConnectHost, as proxies accept hosts which are not DNS-1123 names.
+func normalizeConnectHostname(us *v1.Upstream, hostname string, opts ConnectHostnameOptions) (string, error) {
+	// the prefix is matched regardless of case, like the host name it is stored with
+	prefix := opts.PrefixToStrip
+	if prefix != "" && len(hostname) >= len(prefix) && strings.EqualFold(hostname[:len(prefix)], prefix) {
+		hostname = hostname[len(prefix):]
+	}
+
+	host := hostname
+	if h, port, err := net.SplitHostPort(hostname); err == nil {
+		host = h
+		if portNum, err := strconv.ParseUint(port, 10, 16); err != nil || portNum == 0 {
+			return "", InvalidConnectHostnameError(us, hostname, "invalid port")
+		}
+	}
+	if host == "" {
+		return "", InvalidConnectHostnameError(us, hostname, "missing host")
+	}
+
+	if opts.PreserveCase {
+		return hostname, nil
+	}
+	return strings.ToLower(hostname), nil
+}
+
+// validateConnectHost errors if the host of a normalized CONNECT hostname is neither an IP nor a DNS-1123 subdomain.
+// Such hosts, e.g. with underscores or a trailing dot, may still be resolved by the HTTP CONNECT proxy, so this is
+// only a hard error in strict mode.
+func validateConnectHost(us *v1.Upstream, hostname string) error {
+	host := hostname
+	if h, _, err := net.SplitHostPort(hostname); err == nil {
+		host = h
+	}
+	if net.ParseIP(host) != nil {
+		return nil
+	}
+	if errs := validation.IsDNS1123Subdomain(strings.ToLower(host)); len(errs) > 0 {
+		return InvalidConnectHostnameError(us, hostname, strings.Join(errs, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package tunneling
+
+type Option func(p *plugin)
+
+// WithExperimentalConfig sets the experimental configuration of the plugin, see ExperimentalConfig.
+// The settings of the tunnels of upstreams set here are the defaults the annotations of each upstream override.
+func WithExperimentalConfig(cfg ExperimentalConfig) Option {
+	return func(p *plugin) {
+		p.experimental = cfg
+	}
+}
+
+// WithTracing emits trace spans around the phases of tunneling resource generation, so that translation time can be
+// broken down in a distributed trace
+func WithTracing(enabled bool) Option {
+	return func(p *plugin) {
+		p.experimental.Tracing = enabled
+	}
+}
+
+// WithAuditLogging logs, at info level, the CONNECT hostname targeted by each tunneling upstream during translation,
+// so that egress destinations are recorded in the control plane logs
+func WithAuditLogging(enabled bool) Option {
+	return func(p *plugin) {
+		p.experimental.AuditLogging = enabled
+	}
+}
+
+// WithStrictMode returns an error when the tunneling configuration of an upstream cannot be resolved, e.g. when the
+// secret of its HTTP CONNECT TLS configuration is missing. By default, such an upstream is not tunneled, and the error
+// is reported on the proxy as a message about the upstream.
+// Strict mode rejects the configuration instead, so that egress traffic is never silently left untunneled.
+// Strict mode also fails translation on inconsistencies between the protocol used to reach the HTTP CONNECT proxy and
+// the TLS configuration of the connection to it, and on CONNECT hosts which are not DNS-1123 names, which are otherwise
+// reported as warnings.
+func WithStrictMode(enabled bool) Option {
+	return func(p *plugin) {
+		p.experimental.StrictMode = enabled
+	}
+}
+
+// WithPreservedHostnameCase sends the CONNECT hostname of tunneling upstreams with the case it is configured with.
+// By default, it is lowercased, as host names are case-insensitive; some HTTP CONNECT proxies however compare the
+// authority of CONNECT requests verbatim.
+func WithPreservedHostnameCase(preserve bool) Option {
+	return func(p *plugin) {
+		p.experimental.ConnectHostname.PreserveCase = preserve
+	}
+}
+
+// WithHostnamePrefixStripped strips the prefix from the CONNECT hostname of tunneling upstreams which start with it,
+// for environments which store the hostname of the HTTP CONNECT proxy with a scheme-like prefix such as "http://".
+// The hostname is validated once stripped; an empty prefix strips nothing.
+func WithHostnamePrefixStripped(prefix string) Option {
+	return func(p *plugin) {
+		p.experimental.ConnectHostname.PrefixToStrip = prefix
+	}
+}
+
+// WithGeneratedResourceValidation validates the generated resources before returning them, so that a generation bug
+// fails translation with a clear error rather than being NACKed by envoy. As this validates every generated resource on
+// every translation, it is meant for debugging.
+func WithGeneratedResourceValidation(enabled bool) Option {
+	return func(p *plugin) {
+		p.experimental.GeneratedResourceValidation = enabled
+	}
+}
+
+// WithUnreferencedUpstreamWarnings adds an informational message to the proxy report for each tunneling upstream which
+// no route references, e.g. when the proxy has no route configurations. Nothing is generated for such upstreams, which
+// is correct, but otherwise gives no feedback that tunneling is inert for them.
+func WithUnreferencedUpstreamWarnings(enabled bool) Option {
+	return func(p *plugin) {
+		p.experimental.UnreferencedUpstreamWarnings = enabled
+	}
+}
@@ -1,41 +1,70 @@
 package tunneling
 
 import (
+	"context"
+	"fmt"
+
 	envoy_config_cluster_v3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
 	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	envoy_config_endpoint_v3 "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
 	envoy_config_listener_v3 "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
 	envoy_config_route_v3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
 	envoytcp "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/tcp_proxy/v3"
+	envoyauth "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
 	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+	"github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/ptypes/duration"
 	"github.com/golang/protobuf/ptypes/wrappers"
+	v1 "github.com/solo-io/gloo/projects/gloo/pkg/api/v1"
 	"github.com/solo-io/gloo/projects/gloo/pkg/plugins"
 	"github.com/solo-io/gloo/projects/gloo/pkg/translator"
 	"github.com/solo-io/gloo/projects/gloo/pkg/utils"
+	"github.com/solo-io/gloo/projects/gloo/pkg/xds"
+	"github.com/solo-io/go-utils/contextutils"
+	"github.com/solo-io/solo-kit/pkg/api/v1/resources/core"
+	"go.uber.org/zap"
 	"k8s.io/apimachinery/pkg/util/sets"
 )
 
 var (
-	_ plugins.Plugin                  = new(plugin)
-	_ plugins.ResourceGeneratorPlugin = new(plugin)
+	_ plugins.Plugin                           = new(plugin)
+	_ plugins.ResourceGeneratorPlugin          = new(plugin)
+	_ plugins.GeneratedResourceValidatorPlugin = new(plugin)
 )
 
 const (
 	ExtensionName = "tunneling"
+
+	// name prefixes of the generated self clusters and forwarding listeners
+	SelfClusterPrefix  = "solo_io_generated_self_cluster_"
+	SelfListenerPrefix = "solo_io_generated_self_listener_"
 )
 
-type plugin struct{}
+var (
+	UnreferencedTunnelingUpstreamMessage = func(us *v1.Upstream) string {
+		return fmt.Sprintf("tunneling upstream %s is not referenced by any route, no tunneling resources were generated for it", us.GetMetadata().Ref().Key())
+	}
+)
 
-func NewPlugin() *plugin {
-	return &plugin{}
+type plugin struct {
+	settings     *v1.Settings
+	experimental ExperimentalConfig
+}
+
+func NewPlugin(opts ...Option) *plugin {
+	p := &plugin{}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
 }
 
 func (p *plugin) Name() string {
 	return ExtensionName
 }
 
-func (p *plugin) Init(_ plugins.InitParams) {
+func (p *plugin) Init(params plugins.InitParams) {
+	p.settings = params.Settings
 }
 
 func (p *plugin) GeneratedResources(params plugins.Params,
@@ -44,112 +73,406 @@ func (p *plugin) GeneratedResources(params plugins.Params,
 	inRouteConfigurations []*envoy_config_route_v3.RouteConfiguration,
 	inListeners []*envoy_config_listener_v3.Listener,
 ) ([]*envoy_config_cluster_v3.Cluster, []*envoy_config_endpoint_v3.ClusterLoadAssignment, []*envoy_config_route_v3.RouteConfiguration, []*envoy_config_listener_v3.Listener, error) {
+	generated, err := p.GenerateTunnelingResources(params, inClusters, inRouteConfigurations)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	if p.experimental.GeneratedResourceValidation {
+		if err := ValidateTunnelingResources(generated); err != nil {
+			return nil, nil, nil, nil, err
+		}
+	}
+	return generated.Clusters, generated.Endpoints, nil, generated.Listeners, nil
+}
+
+// TunnelingResources are the resources generated for tunneling upstreams.
+// Generation also modifies input resources in place: routes are rewritten to the generated clusters, and the clusters
+// targeted by tunneling routes are updated to reach the HTTP CONNECT proxy. ModifiedClusters accounts for the latter.
+type TunnelingResources struct {
+	Clusters  []*envoy_config_cluster_v3.Cluster
+	Endpoints []*envoy_config_endpoint_v3.ClusterLoadAssignment
+	Listeners []*envoy_config_listener_v3.Listener
+	// names of the input clusters which were modified in place, e.g. their transport socket was removed or replaced
+	ModifiedClusters sets.String
+	// the tunneling upstream each generated or modified resource is attributed to, by resource name
+	SourceUpstreams map[string]*core.ResourceRef
+}
+
+// GenerateTunnelingResources generates the resources for all the tunneling upstreams referenced by the input routes.
+// If the tunneling configuration of an upstream cannot be resolved, the upstream is not tunneled and the error is
+// reported on the proxy as a message about the upstream, or returned in strict mode. The tunnels of all the upstreams
+// are resolved before the input resources are modified, so that they are left untouched if an error is returned.
+func (p *plugin) GenerateTunnelingResources(params plugins.Params,
+	inClusters []*envoy_config_cluster_v3.Cluster,
+	inRouteConfigurations []*envoy_config_route_v3.RouteConfiguration,
+) (*TunnelingResources, error) {
 
-	var generatedClusters []*envoy_config_cluster_v3.Cluster
-	var generatedListeners []*envoy_config_listener_v3.Listener
+	ctx, span := p.startSpan(params.Ctx, GenerateSpanName)
+	defer span.End()
 
-	upstreams := params.Snapshot.Upstreams
+	generated := &TunnelingResources{
+		ModifiedClusters: sets.NewString(),
+		SourceUpstreams:  map[string]*core.ResourceRef{},
+	}
 
 	// keep track of clusters we've seen in case of multiple routes to same cluster
-	processedClusters := sets.NewString()
+	tunnels := map[string]*resolvedTunnel{}
+	// the clusters in the order their tunnels were resolved, so that the generated resources are deterministic
+	var tunneledClusters []string
+	// keep track of the clusters of tunneling upstreams which could not be tunneled, whose routes are left untouched
+	skippedClusters := sets.NewString()
+	// keep track of the upstream each pipe is generated for, so that no two upstreams share a pipe
+	pipeOwners := map[string]string{}
+	// the tunneling upstreams by cluster name; upstreams whose names map to the same cluster name all target it
+	tunnelingUpstreams := map[string]v1.UpstreamList{}
+	for _, us := range params.Snapshot.Upstreams {
+		if isTunnelingUpstream(us) {
+			cluster := translator.UpstreamToClusterName(us.GetMetadata().Ref())
+			tunnelingUpstreams[cluster] = append(tunnelingUpstreams[cluster], us)
+		}
+	}
+
+	ctx, scanSpan := p.startSpan(ctx, ScanSpanName)
+	defer scanSpan.End()
 
 	// find all the route config that points to upstreams with tunneling
 	for _, rtConfig := range inRouteConfigurations {
 		for _, vh := range rtConfig.GetVirtualHosts() {
 			for _, rt := range vh.GetRoutes() {
+				// only routes forwarding to a cluster can be tunneled; redirect and direct response routes never
+				// reach an upstream, and are left untouched
+				if _, ok := rt.GetAction().(*envoy_config_route_v3.Route_Route); !ok {
+					continue
+				}
 				rtAction := rt.GetRoute()
 				// we do not handle the weighted cluster or cluster header cases
-				if cluster := rtAction.GetCluster(); cluster != "" {
+				cluster := rtAction.GetCluster()
+				if cluster == "" || skippedClusters.Has(cluster) {
+					continue
+				}
 
-					ref, err := translator.ClusterToUpstreamRef(cluster)
-					if err != nil {
-						// return what we have so far, so that any modified input resources can still route
-						// successfully to their generated targets
-						return generatedClusters, nil, nil, generatedListeners, nil
-					}
+				clusterUpstreams := tunnelingUpstreams[cluster]
+				if len(clusterUpstreams) == 0 {
+					// not the cluster of a tunneling upstream
+					continue
+				}
+				us := clusterUpstreams[0]
 
-					us, err := upstreams.Find(ref.GetNamespace(), ref.GetName())
-					if err != nil {
-						// return what we have so far, so that any modified input resources can still route
-						// successfully to their generated targets
-						return generatedClusters, nil, nil, generatedListeners, nil
+				// we only want to generate a new encapsulating cluster and pipe to ourselves if we have not done so already
+				tunnel, ok := tunnels[cluster]
+				if !ok {
+					err := claimPipes(p.experimental, p.settings, pipeOwners, cluster, clusterUpstreams)
+					if err == nil {
+						tunnel, err = p.resolveTunnel(ctx, params, us, cluster, inClusters)
 					}
-
-					// the existence of this value is our indicator that this is a tunneling upstream
-					tunnelingHostname := us.GetHttpProxyHostname().GetValue()
-					if tunnelingHostname == "" {
+					if err != nil {
+						if err := p.failOrReport(params, us, err); err != nil {
+							return nil, err
+						}
+						// the routes to the upstream keep targeting its cluster, which is left untouched
+						skippedClusters.Insert(cluster)
 						continue
 					}
+					tunnels[cluster] = tunnel
+					tunneledClusters = append(tunneledClusters, cluster)
+				}
+				tunnel.routes = append(tunnel.routes, rtAction)
+			}
+		}
+	}
 
-					var tunnelingHeaders []*envoy_config_core_v3.HeaderValueOption
-					for _, header := range us.GetHttpConnectHeaders() {
-						tunnelingHeaders = append(tunnelingHeaders, &envoy_config_core_v3.HeaderValueOption{
-							Header: &envoy_config_core_v3.HeaderValue{
-								Key:   header.GetKey(),
-								Value: header.GetValue(),
-							},
-							Append: &wrappers.BoolValue{Value: false},
-						})
-					}
+	// every tunnel is resolved, the input resources can now be modified
+	for _, cluster := range tunneledClusters {
+		p.commitTunnel(params, tunnels[cluster], generated)
+	}
 
-					selfCluster := "solo_io_generated_self_cluster_" + cluster
-					selfPipe := "@/" + cluster // use an in-memory pipe to ourselves (only works on linux)
+	if p.experimental.UnreferencedUpstreamWarnings {
+		warnUnreferencedTunnelingUpstreams(params, sets.StringKeySet(tunnels).Union(skippedClusters))
+	}
 
-					// update the old cluster to route to ourselves first
-					rtAction.ClusterSpecifier = &envoy_config_route_v3.RouteAction_Cluster{Cluster: selfCluster}
+	return generated, nil
+}
 
-					// we only want to generate a new encapsulating cluster and pipe to ourselves if we have not done so already
-					if processedClusters.Has(cluster) {
-						continue
-					}
-					var originalTransportSocket *envoy_config_core_v3.TransportSocket
-					for _, inCluster := range inClusters {
-						if inCluster.GetName() == cluster {
-							if inCluster.GetTransportSocket() != nil {
-								tmp := *inCluster.GetTransportSocket()
-								originalTransportSocket = &tmp
-							}
-							// we copy the transport socket to the generated cluster.
-							// the generated cluster will use upstream TLS context to leverage TLS origination;
-							// when we encapsulate in HTTP Connect the tcp data being proxied will
-							// be encrypted (thus we don't need the original transport socket metadata here)
-							inCluster.TransportSocket = nil
-							inCluster.TransportSocketMatches = nil
-
-							if us.GetHttpConnectSslConfig() == nil {
-								break
-							}
-							// user told us to configure ssl for the http connect proxy
-							cfg, err := utils.NewSslConfigTranslator().ResolveUpstreamSslConfig(params.Snapshot.Secrets, us.GetHttpConnectSslConfig())
-							if err != nil {
-								// return what we have so far, so that any modified input resources can still route
-								// successfully to their generated targets
-								return generatedClusters, nil, nil, generatedListeners, nil
-							}
-							typedConfig, err := utils.MessageToAny(cfg)
-							if err != nil {
-								return nil, nil, nil, nil, err
-							}
-							inCluster.TransportSocket = &envoy_config_core_v3.TransportSocket{
-								Name:       wellknown.TransportSocketTls,
-								ConfigType: &envoy_config_core_v3.TransportSocket_TypedConfig{TypedConfig: typedConfig},
-							}
-							break
-						}
-					}
-					generatedClusters = append(generatedClusters, generateSelfCluster(selfCluster, selfPipe, originalTransportSocket))
-					forwardingTcpListener, err := generateForwardingTcpListener(cluster, selfPipe, tunnelingHostname, tunnelingHeaders)
-					if err != nil {
-						return nil, nil, nil, nil, err
-					}
-					generatedListeners = append(generatedListeners, forwardingTcpListener)
-					processedClusters.Insert(cluster)
-				}
+// resolvedTunnel is everything tunneling the traffic of the cluster of a tunneling upstream through its HTTP CONNECT
+// proxy, resolved without modifying the input resources
+type resolvedTunnel struct {
+	us      *v1.Upstream
+	cluster string
+	// the input cluster, and the copy replacing it to reach the proxy; nil if the cluster is not updated
+	inCluster, tunneledCluster *envoy_config_cluster_v3.Cluster
+	// whether the input cluster is modified by its replacement
+	modified        bool
+	connectHostname string
+	selfCluster     *envoy_config_cluster_v3.Cluster
+	// the endpoints of the self cluster when served over EDS, nil otherwise
+	selfEndpoints *envoy_config_endpoint_v3.ClusterLoadAssignment
+	listener      *envoy_config_listener_v3.Listener
+	// the routes to the cluster, which are rewritten to the self cluster
+	routes []*envoy_config_route_v3.RouteAction
+}
+
+// resolveTunnel resolves the resources tunneling the traffic of the cluster of a tunneling upstream through its
+// HTTP CONNECT proxy, along with the update of the cluster to reach the proxy. Nothing is modified until the tunnel is
+// committed.
+func (p *plugin) resolveTunnel(ctx context.Context, params plugins.Params, us *v1.Upstream, cluster string,
+	inClusters []*envoy_config_cluster_v3.Cluster) (*resolvedTunnel, error) {
+
+	tunnelingCfg, err := tunnelingConfigForUpstream(p.experimental, p.settings, us)
+	if err != nil {
+		return nil, err
+	}
+	proxyCluster, err := tunnelingCfg.proxyCluster(us, params.Snapshot.Upstreams)
+	if err != nil {
+		return nil, err
+	}
+	tunnelingHostname, err := normalizeConnectHostname(us, us.GetHttpProxyHostname().GetValue(), p.experimental.ConnectHostname)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateConnectHost(us, tunnelingHostname); err != nil {
+		if err := p.failOrReport(params, us, err); err != nil {
+			return nil, err
+		}
+	}
+	for _, inconsistency := range httpConnectProtocolInconsistencies(us, tunnelingCfg.httpProtocolAutoDetect) {
+		if err := p.failOrReport(params, us, inconsistency); err != nil {
+			return nil, err
+		}
+	}
+
+	var tunnelingHeaders []*envoy_config_core_v3.HeaderValueOption
+	for _, header := range us.GetHttpConnectHeaders() {
+		tunnelingHeaders = append(tunnelingHeaders, &envoy_config_core_v3.HeaderValueOption{
+			Header: &envoy_config_core_v3.HeaderValue{
+				Key:   header.GetKey(),
+				Value: header.GetValue(),
+			},
+			Append: &wrappers.BoolValue{Value: false},
+		})
+	}
+
+	selfCluster := SelfClusterPrefix + cluster
+	selfPipe, err := tunnelingCfg.validatedPipePath(us, cluster) // use an in-memory pipe to ourselves
+	if err != nil {
+		return nil, err
+	}
+
+	var inCluster, tunneledCluster *envoy_config_cluster_v3.Cluster
+	for _, candidate := range inClusters {
+		if candidate.GetName() == cluster {
+			inCluster = candidate
+			break
+		}
+	}
+	modified := false
+	var originalTransportSocket *envoy_config_core_v3.TransportSocket
+	if inCluster != nil && inCluster.GetTransportSocket() != nil {
+		originalTransportSocket = proto.Clone(inCluster.GetTransportSocket()).(*envoy_config_core_v3.TransportSocket)
+	}
+	// the cluster is only updated when it is the one reaching the HTTP CONNECT proxy
+	if inCluster != nil && proxyCluster == cluster {
+		// the cluster is updated on a copy, which replaces it once everything is resolved
+		tunneledCluster = proto.Clone(inCluster).(*envoy_config_cluster_v3.Cluster)
+		modified = inCluster.GetTransportSocket() != nil || len(inCluster.GetTransportSocketMatches()) > 0
+		// we copy the transport socket to the generated cluster.
+		// the generated cluster will use upstream TLS context to leverage TLS origination;
+		// when we encapsulate in HTTP Connect the tcp data being proxied will
+		// be encrypted (thus we don't need the original transport socket metadata here)
+		tunneledCluster.TransportSocket = nil
+		tunneledCluster.TransportSocketMatches = nil
+
+		if tunnelingCfg.happyEyeballs {
+			if err := enableHappyEyeballs(us, tunneledCluster); err != nil {
+				return nil, err
+			}
+			modified = true
+		}
+
+		if tunnelingCfg.httpProtocolAutoDetect {
+			if err := enableHttpProtocolAutoDetection(tunneledCluster); err != nil {
+				return nil, err
+			}
+			modified = true
+		}
+
+		// user told us to configure ssl for the http connect proxy
+		if us.GetHttpConnectSslConfig() != nil {
+			_, tlsSpan := p.startSpan(ctx, ResolveTlsSpanName)
+			cfg, err := utils.NewSslConfigTranslator().ResolveUpstreamSslConfig(params.Snapshot.Secrets, us.GetHttpConnectSslConfig())
+			tlsSpan.End()
+			if err != nil {
+				return nil, HttpConnectSslConfigError(us, err)
+			}
+			tunnelingCfg.applyToUpstreamTlsContext(cfg)
+			_, marshalSpan := p.startSpan(ctx, MarshalSpanName)
+			typedConfig, err := utils.MessageToAny(cfg)
+			marshalSpan.End()
+			if err != nil {
+				return nil, err
+			}
+			tunneledCluster.TransportSocket = &envoy_config_core_v3.TransportSocket{
+				Name:       wellknown.TransportSocketTls,
+				ConfigType: &envoy_config_core_v3.TransportSocket_TypedConfig{TypedConfig: typedConfig},
 			}
+			modified = true
+		}
+	}
+
+	_, tlsContextSpan := p.startSpan(ctx, UpdateTlsContextSpanName)
+	err = updateUpstreamTlsContext(originalTransportSocket, tunnelingCfg)
+	tlsContextSpan.End()
+	if err != nil {
+		return nil, err
+	}
+	generatedSelfCluster := generateSelfCluster(selfCluster, selfPipe, originalTransportSocket)
+	tunnelingCfg.applyToSelfCluster(generatedSelfCluster)
+	var generatedEndpoints *envoy_config_endpoint_v3.ClusterLoadAssignment
+	if tunnelingCfg.edsSelfCluster {
+		// serve the pipe endpoint over EDS rather than inline in the cluster
+		generatedEndpoints = generatedSelfCluster.GetLoadAssignment()
+		generatedSelfCluster.LoadAssignment = nil
+		xds.SetEdsOnCluster(generatedSelfCluster, p.settings)
+	}
+	_, marshalSpan := p.startSpan(ctx, MarshalSpanName)
+	forwardingTcpListener, err := generateForwardingTcpListener(cluster, proxyCluster, selfPipe, tunnelingHostname, tunnelingHeaders, tunnelingCfg)
+	marshalSpan.End()
+	if err != nil {
+		return nil, err
+	}
+
+	return &resolvedTunnel{
+		us:              us,
+		cluster:         cluster,
+		inCluster:       inCluster,
+		tunneledCluster: tunneledCluster,
+		modified:        modified,
+		connectHostname: tunnelingHostname,
+		selfCluster:     generatedSelfCluster,
+		selfEndpoints:   generatedEndpoints,
+		listener:        forwardingTcpListener,
+	}, nil
+}
+
+// commitTunnel updates the cluster of the tunnel to reach the HTTP CONNECT proxy, rewrites its routes to the self
+// cluster, and adds the generated resources
+func (p *plugin) commitTunnel(params plugins.Params, tunnel *resolvedTunnel, generated *TunnelingResources) {
+	if tunnel.tunneledCluster != nil {
+		proto.Reset(tunnel.inCluster)
+		proto.Merge(tunnel.inCluster, tunnel.tunneledCluster)
+	}
+	if tunnel.modified {
+		generated.ModifiedClusters.Insert(tunnel.cluster)
+	}
+	// update the old cluster to route to ourselves first
+	for _, rtAction := range tunnel.routes {
+		rtAction.ClusterSpecifier = &envoy_config_route_v3.RouteAction_Cluster{Cluster: tunnel.selfCluster.GetName()}
+	}
+	if p.experimental.AuditLogging {
+		contextutils.LoggerFrom(params.Ctx).Infow("tunneling upstream targets CONNECT hostname",
+			zap.String("upstream", tunnel.us.GetMetadata().Ref().Key()),
+			zap.String("connectHostname", tunnel.connectHostname))
+	}
+	ref := tunnel.us.GetMetadata().Ref()
+	generated.SourceUpstreams[tunnel.cluster] = ref
+	generated.SourceUpstreams[tunnel.selfCluster.GetName()] = ref
+	if tunnel.selfEndpoints != nil {
+		generated.Endpoints = append(generated.Endpoints, tunnel.selfEndpoints)
+	}
+	generated.Clusters = append(generated.Clusters, tunnel.selfCluster)
+	generated.Listeners = append(generated.Listeners, tunnel.listener)
+	generated.SourceUpstreams[tunnel.listener.GetName()] = ref
+}
+
+// tunneling upstreams which no route references are correctly left without generated resources, but tunneling is then
+// inert for them, which is reported on the proxy as an informational message
+func warnUnreferencedTunnelingUpstreams(params plugins.Params, referencedClusters sets.String) {
+	for _, us := range params.Snapshot.Upstreams {
+		if !isTunnelingUpstream(us) || referencedClusters.Has(translator.UpstreamToClusterName(us.GetMetadata().Ref())) {
+			continue
+		}
+		reportMessage(params, us, UnreferencedTunnelingUpstreamMessage(us))
+	}
+}
+
+// claims the pipes of the tunneling upstreams targeting the cluster, and errors if one of them is owned by another
+// upstream. Distinct upstreams share a pipe when their names map to the same cluster name, e.g. a_b.c and a.b_c.
+// Upstreams whose tunneling configuration cannot be resolved are left to resolveTunnel to report.
+func claimPipes(defaults ExperimentalConfig, settings *v1.Settings, pipeOwners map[string]string, cluster string, clusterUpstreams v1.UpstreamList) error {
+	for _, us := range clusterUpstreams {
+		cfg, err := tunnelingConfigForUpstream(defaults, settings, us)
+		if err != nil {
+			continue
+		}
+		pipe := cfg.pipePath(cluster)
+		upstream := us.GetMetadata().Ref().Key()
+		if owner, ok := pipeOwners[pipe]; ok && owner != upstream {
+			return PipePathCollisionError(pipe, upstream, owner)
 		}
+		pipeOwners[pipe] = upstream
 	}
+	return nil
+}
 
-	return generatedClusters, nil, nil, generatedListeners, nil
+// in strict mode, problems with the configuration of a tunneling upstream fail translation; otherwise they are
+// reported on the proxy as messages about the upstream
+func (p *plugin) failOrReport(params plugins.Params, us *v1.Upstream, err error) error {
+	if p.experimental.StrictMode {
+		return err
+	}
+	reportMessage(params, us, err.Error())
+	return nil
+}
+
+func reportMessage(params plugins.Params, us *v1.Upstream, message string) {
+	if params.Messages == nil {
+		return
+	}
+	ref := us.GetMetadata().Ref()
+	params.Messages[ref] = append(params.Messages[ref], message)
+}
+
+// GeneratedResourcesByUpstream runs generation and groups the resources by the tunneling upstream they are attributed
+// to, keyed by the key of the upstream ref, so that everything a single tunneling upstream produced can be inspected.
+// It is meant for debugging; like GenerateTunnelingResources, it modifies the input resources in place.
+func (p *plugin) GeneratedResourcesByUpstream(params plugins.Params,
+	inClusters []*envoy_config_cluster_v3.Cluster,
+	inRouteConfigurations []*envoy_config_route_v3.RouteConfiguration,
+) (map[string]*TunnelingResources, error) {
+	generated, err := p.GenerateTunnelingResources(params, inClusters, inRouteConfigurations)
+	if err != nil {
+		return nil, err
+	}
+
+	byUpstream := map[string]*TunnelingResources{}
+	resourcesFor := func(name string) *TunnelingResources {
+		ref := generated.SourceUpstreams[name]
+		resources, ok := byUpstream[ref.Key()]
+		if !ok {
+			resources = &TunnelingResources{
+				ModifiedClusters: sets.NewString(),
+				SourceUpstreams:  map[string]*core.ResourceRef{},
+			}
+			byUpstream[ref.Key()] = resources
+		}
+		resources.SourceUpstreams[name] = ref
+		return resources
+	}
+	for _, cluster := range generated.Clusters {
+		resources := resourcesFor(cluster.GetName())
+		resources.Clusters = append(resources.Clusters, cluster)
+	}
+	for _, endpoints := range generated.Endpoints {
+		resources := resourcesFor(endpoints.GetClusterName())
+		resources.Endpoints = append(resources.Endpoints, endpoints)
+	}
+	for _, listener := range generated.Listeners {
+		resources := resourcesFor(listener.GetName())
+		resources.Listeners = append(resources.Listeners, listener)
+	}
+	for _, cluster := range generated.ModifiedClusters.List() {
+		resourcesFor(cluster).ModifiedClusters.Insert(cluster)
+	}
+	return byUpstream, nil
 }
 
 // the initial route is updated to route to this generated cluster, which routes envoy back to itself (to the
@@ -191,19 +514,58 @@ func generateSelfCluster(selfCluster, selfPipe string, originalTransportSocket *
 	}
 }
 
+// applies the tunneling settings to the UpstreamTlsContext carried by the transport socket, if any.
+// the typed config is replaced rather than modified, as it is shared with the input cluster's transport socket
+func updateUpstreamTlsContext(transportSocket *envoy_config_core_v3.TransportSocket, tunnelingCfg *tunnelingConfig) error {
+	if transportSocket.GetTypedConfig() == nil {
+		return nil
+	}
+	msg, err := utils.AnyToMessage(transportSocket.GetTypedConfig())
+	if err != nil {
+		return err
+	}
+	tlsContext, ok := msg.(*envoyauth.UpstreamTlsContext)
+	if !ok {
+		return nil
+	}
+	updatedTlsContext := proto.Clone(tlsContext).(*envoyauth.UpstreamTlsContext)
+	tunnelingCfg.applyToUpstreamTlsContext(updatedTlsContext)
+	if proto.Equal(tlsContext, updatedTlsContext) {
+		return nil
+	}
+	typedConfig, err := utils.MessageToAny(updatedTlsContext)
+	if err != nil {
+		return err
+	}
+	transportSocket.ConfigType = &envoy_config_core_v3.TransportSocket_TypedConfig{TypedConfig: typedConfig}
+	return nil
+}
+
+// envoy attempts connections using happy eyeballs when resolving both address families for a logical DNS cluster.
+// strict DNS clusters are not supported: envoy creates a host per resolved address and load balances across IPv4 and
+// IPv6 hosts without racing them, which breaks connections in IPv4-only environments.
+func enableHappyEyeballs(us *v1.Upstream, cluster *envoy_config_cluster_v3.Cluster) error {
+	if cluster.GetType() != envoy_config_cluster_v3.Cluster_LOGICAL_DNS {
+		return HappyEyeballsClusterTypeError(us, cluster.GetType())
+	}
+	cluster.DnsLookupFamily = envoy_config_cluster_v3.Cluster_ALL
+	return nil
+}
+
 // the generated cluster routes to this generated listener, which forwards TCP traffic to an HTTP Connect proxy
-func generateForwardingTcpListener(cluster, selfPipe, tunnelingHostname string, tunnelingHeadersToAdd []*envoy_config_core_v3.HeaderValueOption) (*envoy_config_listener_v3.Listener, error) {
+func generateForwardingTcpListener(cluster, proxyCluster, selfPipe, tunnelingHostname string, tunnelingHeadersToAdd []*envoy_config_core_v3.HeaderValueOption, tunnelingCfg *tunnelingConfig) (*envoy_config_listener_v3.Listener, error) {
 	cfg := &envoytcp.TcpProxy{
 		StatPrefix:       "soloioTcpStats" + cluster,
 		TunnelingConfig:  &envoytcp.TcpProxy_TunnelingConfig{Hostname: tunnelingHostname, HeadersToAdd: tunnelingHeadersToAdd},
-		ClusterSpecifier: &envoytcp.TcpProxy_Cluster{Cluster: cluster}, // route to original target
+		ClusterSpecifier: &envoytcp.TcpProxy_Cluster{Cluster: proxyCluster}, // route to the HTTP Connect proxy
 	}
+	tunnelingCfg.applyToTcpProxy(cfg)
 	typedConfig, err := utils.MessageToAny(cfg)
 	if err != nil {
 		return nil, err
 	}
 	return &envoy_config_listener_v3.Listener{
-		Name: "solo_io_generated_self_listener_" + cluster,
+		Name: SelfListenerPrefix + cluster,
 		Address: &envoy_config_core_v3.Address{
 			Address: &envoy_config_core_v3.Address_Pipe{
 				Pipe: &envoy_config_core_v3.Pipe{
@@ -1,23 +1,44 @@
 package tunneling_test
 
 import (
+	"context"
+	"os"
+	"strings"
+	"sync"
+
 	envoy_config_cluster_v3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
 	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	envoy_config_endpoint_v3 "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	envoy_config_listener_v3 "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
 	envoy_config_route_v3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
 	envoytcp "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/tcp_proxy/v3"
 	envoyauth "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	envoy_extensions_upstreams_http_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/upstreams/http/v3"
+	"github.com/golang/protobuf/ptypes/duration"
 	"github.com/golang/protobuf/ptypes/wrappers"
 	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
 	. "github.com/onsi/gomega"
 	v1 "github.com/solo-io/gloo/projects/gloo/pkg/api/v1"
 	v1snap "github.com/solo-io/gloo/projects/gloo/pkg/api/v1/gloosnapshot"
+	"github.com/solo-io/gloo/projects/gloo/pkg/api/v1/options/kubernetes"
+	"github.com/solo-io/gloo/projects/gloo/pkg/api/v1/options/protocol"
 	"github.com/solo-io/gloo/projects/gloo/pkg/plugins"
 	"github.com/solo-io/gloo/projects/gloo/pkg/plugins/tunneling"
+	"github.com/solo-io/gloo/projects/gloo/pkg/plugins/upstreamconn"
 	"github.com/solo-io/gloo/projects/gloo/pkg/translator"
 	"github.com/solo-io/gloo/projects/gloo/pkg/utils"
+	"github.com/solo-io/gloo/projects/gloo/pkg/xds"
+	"github.com/solo-io/go-utils/contextutils"
 	"github.com/solo-io/skv2/test/matchers"
+	envoycache "github.com/solo-io/solo-kit/pkg/api/v1/control-plane/cache"
+	"github.com/solo-io/solo-kit/pkg/api/v1/control-plane/resource"
+	"github.com/solo-io/solo-kit/pkg/api/v1/control-plane/types"
 	"github.com/solo-io/solo-kit/pkg/api/v1/resources/core"
+	"go.opencensus.io/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
 	"google.golang.org/protobuf/proto"
 )
 
@@ -31,6 +52,52 @@ var _ = Describe("Plugin", func() {
 		params                plugins.Params
 		inRouteConfigurations []*envoy_config_route_v3.RouteConfiguration
 		inClusters            []*envoy_config_cluster_v3.Cluster
+		us                    *v1.Upstream
+		messages              map[*core.ResourceRef][]string
+	)
+
+	// the messages reported for the upstream with the given ref
+	messagesFor := func(ref *core.ResourceRef) []string {
+		var refMessages []string
+		for messageRef, msgs := range messages {
+			if messageRef.Equal(ref) {
+				refMessages = append(refMessages, msgs...)
+			}
+		}
+		return refMessages
+	}
+
+	// the TcpProxy of a generated forwarding listener
+	getTcpProxy := func(listener *envoy_config_listener_v3.Listener) *envoytcp.TcpProxy {
+		return utils.MustAnyToMessage(listener.GetFilterChains()[0].GetFilters()[0].GetTypedConfig()).(*envoytcp.TcpProxy)
+	}
+
+	// adds a tunneling upstream copied from the default one, along with a route to it and its cluster
+	addUpstream := func(name, namespace string, annotations map[string]string) (*v1.Upstream, *envoy_config_route_v3.Route, *envoy_config_cluster_v3.Cluster) {
+		addedUs := &v1.Upstream{}
+		us.DeepCopyInto(addedUs)
+		addedUs.Metadata.Name = name
+		addedUs.Metadata.Namespace = namespace
+		addedUs.Metadata.Annotations = annotations
+		params.Snapshot.Upstreams = append(params.Snapshot.Upstreams, addedUs)
+
+		clusterName := translator.UpstreamToClusterName(addedUs.Metadata.Ref())
+		addedRoute := proto.Clone(inRouteConfigurations[0].VirtualHosts[0].Routes[0]).(*envoy_config_route_v3.Route)
+		addedRoute.Name = addedRoute.Name + "-" + clusterName
+		addedRoute.Action = &envoy_config_route_v3.Route_Route{
+			Route: &envoy_config_route_v3.RouteAction{
+				ClusterSpecifier: &envoy_config_route_v3.RouteAction_Cluster{Cluster: clusterName},
+			},
+		}
+		inRouteConfigurations[0].VirtualHosts[0].Routes = append(inRouteConfigurations[0].VirtualHosts[0].Routes, addedRoute)
+
+		addedCluster := proto.Clone(inClusters[0]).(*envoy_config_cluster_v3.Cluster)
+		addedCluster.Name = clusterName
+		inClusters = append(inClusters, addedCluster)
+		return addedUs, addedRoute, addedCluster
+	}
+
+	BeforeEach(func() {
 
 		us = &v1.Upstream{
 			Metadata: &core.Metadata{
@@ -40,14 +107,13 @@ var _ = Describe("Plugin", func() {
 			SslConfig:         nil,
 			HttpProxyHostname: &wrappers.StringValue{Value: httpProxyHostname},
 		}
-	)
-
-	BeforeEach(func() {
 
+		messages = map[*core.ResourceRef][]string{}
 		params = plugins.Params{
 			Snapshot: &v1snap.ApiSnapshot{
 				Upstreams: []*v1.Upstream{us},
 			},
+			Messages: messages,
 		}
 
 		inRouteConfigurations = []*envoy_config_route_v3.RouteConfiguration{
@@ -264,4 +330,1371 @@ var _ = Describe("Plugin", func() {
 		})
 	})
 
-})
+	Context("TLS session resumption", func() {
+
+		BeforeEach(func() {
+			cfg, err := utils.MessageToAny(&envoyauth.UpstreamTlsContext{
+				CommonTlsContext: &envoyauth.CommonTlsContext{},
+				Sni:              "origin.com",
+			})
+			Expect(err).ToNot(HaveOccurred())
+			inClusters[0].TransportSocket = &envoy_config_core_v3.TransportSocket{
+				Name: "",
+				ConfigType: &envoy_config_core_v3.TransportSocket_TypedConfig{
+					TypedConfig: cfg,
+				},
+			}
+			us.HttpConnectSslConfig = &v1.UpstreamSslConfig{Sni: "host.com"}
+		})
+
+		getUpstreamTlsContext := func(cluster *envoy_config_cluster_v3.Cluster) *envoyauth.UpstreamTlsContext {
+			return utils.MustAnyToMessage(cluster.GetTransportSocket().GetTypedConfig()).(*envoyauth.UpstreamTlsContext)
+		}
+
+		It("should not set max session keys by default", func() {
+			p := tunneling.NewPlugin()
+			generatedClusters, _, _, _, err := p.GeneratedResources(params, inClusters, nil, inRouteConfigurations, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(getUpstreamTlsContext(generatedClusters[0]).GetMaxSessionKeys()).To(BeNil())
+			Expect(getUpstreamTlsContext(inClusters[0]).GetMaxSessionKeys()).To(BeNil())
+		})
+
+		It("should apply the resumption settings to the originated TLS contexts", func() {
+			us.Metadata.Annotations = map[string]string{tunneling.MaxSessionKeysAnnotation: "0"}
+
+			p := tunneling.NewPlugin()
+			generatedClusters, _, _, _, err := p.GeneratedResources(params, inClusters, nil, inRouteConfigurations, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(generatedClusters).To(HaveLen(1))
+
+			// TLS to the origin, originated by the generated self cluster
+			selfTlsContext := getUpstreamTlsContext(generatedClusters[0])
+			Expect(selfTlsContext.GetSni()).To(Equal("origin.com"))
+			Expect(selfTlsContext.GetMaxSessionKeys()).To(matchers.MatchProto(&wrappers.UInt32Value{Value: 0}))
+
+			// TLS to the CONNECT proxy
+			connectTlsContext := getUpstreamTlsContext(inClusters[0])
+			Expect(connectTlsContext.GetSni()).To(Equal("host.com"))
+			Expect(connectTlsContext.GetMaxSessionKeys()).To(matchers.MatchProto(&wrappers.UInt32Value{Value: 0}))
+		})
+
+		It("should error on an invalid resumption setting", func() {
+			us.Metadata.Annotations = map[string]string{tunneling.MaxSessionKeysAnnotation: "-1"}
+
+			p := tunneling.NewPlugin(tunneling.WithStrictMode(true))
+			_, _, _, _, err := p.GeneratedResources(params, inClusters, nil, inRouteConfigurations, nil)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring(tunneling.MaxSessionKeysAnnotation))
+		})
+	})
+
+	Context("modified input clusters", func() {
+
+		var (
+			clusterName string
+		)
+
+		BeforeEach(func() {
+			clusterName = translator.UpstreamToClusterName(us.Metadata.Ref())
+
+			// add a non-tunneling upstream with its own TLS cluster, which should never be modified
+			directUs := &v1.Upstream{
+				Metadata: &core.Metadata{
+					Name:      "direct-upstream",
+					Namespace: "gloo-system",
+				},
+			}
+			params.Snapshot.Upstreams = append(params.Snapshot.Upstreams, directUs)
+
+			directRoute := proto.Clone(inRouteConfigurations[0].VirtualHosts[0].Routes[0]).(*envoy_config_route_v3.Route)
+			directRoute.Name = "directroute"
+			directRoute.GetRoute().ClusterSpecifier = &envoy_config_route_v3.RouteAction_Cluster{
+				Cluster: translator.UpstreamToClusterName(directUs.Metadata.Ref()),
+			}
+			inRouteConfigurations[0].VirtualHosts[0].Routes = append(inRouteConfigurations[0].VirtualHosts[0].Routes, directRoute)
+
+			cfg, err := utils.MessageToAny(&envoyauth.UpstreamTlsContext{Sni: "direct.com"})
+			Expect(err).ToNot(HaveOccurred())
+			directCluster := proto.Clone(inClusters[0]).(*envoy_config_cluster_v3.Cluster)
+			directCluster.Name = translator.UpstreamToClusterName(directUs.Metadata.Ref())
+			directCluster.TransportSocket = &envoy_config_core_v3.TransportSocket{
+				ConfigType: &envoy_config_core_v3.TransportSocket_TypedConfig{TypedConfig: cfg},
+			}
+			inClusters = append(inClusters, directCluster)
+		})
+
+		It("should not report clusters without transport sockets", func() {
+			p := tunneling.NewPlugin()
+			generated, err := p.GenerateTunnelingResources(params, inClusters, inRouteConfigurations)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(generated.Clusters).To(HaveLen(1))
+			Expect(generated.ModifiedClusters.List()).To(BeEmpty())
+		})
+
+		It("should report clusters whose transport socket was removed", func() {
+			cfg, err := utils.MessageToAny(&envoyauth.UpstreamTlsContext{Sni: "origin.com"})
+			Expect(err).ToNot(HaveOccurred())
+			inClusters[0].TransportSocket = &envoy_config_core_v3.TransportSocket{
+				ConfigType: &envoy_config_core_v3.TransportSocket_TypedConfig{TypedConfig: cfg},
+			}
+
+			p := tunneling.NewPlugin()
+			generated, err := p.GenerateTunnelingResources(params, inClusters, inRouteConfigurations)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(generated.ModifiedClusters.List()).To(ConsistOf(clusterName))
+			Expect(inClusters[0].GetTransportSocket()).To(BeNil())
+			Expect(inClusters[1].GetTransportSocket()).ToNot(BeNil(), "non-tunneling cluster should be untouched")
+		})
+
+		It("should report clusters whose transport socket was replaced with TLS to the CONNECT proxy", func() {
+			us.HttpConnectSslConfig = &v1.UpstreamSslConfig{Sni: "host.com"}
+
+			p := tunneling.NewPlugin()
+			generated, err := p.GenerateTunnelingResources(params, inClusters, inRouteConfigurations)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(generated.ModifiedClusters.List()).To(ConsistOf(clusterName))
+			Expect(inClusters[0].GetTransportSocket()).ToNot(BeNil())
+		})
+	})
+
+	Context("proxy service reference", func() {
+
+		var (
+			proxyServiceUs *v1.Upstream
+		)
+
+		BeforeEach(func() {
+			us.Metadata.Annotations = map[string]string{tunneling.ProxyServiceAnnotation: "proxies/squid:3128"}
+
+			proxyServiceUs = &v1.Upstream{
+				Metadata: &core.Metadata{
+					Name:      "proxies-squid-3128",
+					Namespace: "gloo-system",
+				},
+				UpstreamType: &v1.Upstream_Kube{
+					Kube: &kubernetes.UpstreamSpec{
+						ServiceName:      "squid",
+						ServiceNamespace: "proxies",
+						ServicePort:      3128,
+					},
+				},
+			}
+			params.Snapshot.Upstreams = append(params.Snapshot.Upstreams, proxyServiceUs)
+		})
+
+		It("should reach the proxy through the cluster of the service", func() {
+			originalCluster := proto.Clone(inClusters[0])
+
+			p := tunneling.NewPlugin()
+			generatedClusters, _, _, generatedListeners, err := p.GeneratedResources(params, inClusters, nil, inRouteConfigurations, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(generatedClusters).To(HaveLen(1))
+			Expect(generatedListeners).To(HaveLen(1))
+
+			typedTcpConfig := getTcpProxy(generatedListeners[0])
+			Expect(typedTcpConfig.GetCluster()).To(Equal(translator.UpstreamToClusterName(proxyServiceUs.GetMetadata().Ref())))
+			Expect(typedTcpConfig.GetTunnelingConfig().GetHostname()).To(Equal(httpProxyHostname))
+			// the cluster of the upstream no longer reaches the proxy, and is left untouched
+			Expect(inClusters[0]).To(matchers.MatchProto(originalCluster))
+		})
+
+		It("should error if the service is not found", func() {
+			proxyServiceUs.GetKube().ServicePort = 8080
+
+			p := tunneling.NewPlugin(tunneling.WithStrictMode(true))
+			_, _, _, _, err := p.GeneratedResources(params, inClusters, nil, inRouteConfigurations, nil)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("proxies/squid:3128 which was not found"))
+		})
+
+		It("should error on a malformed service reference", func() {
+			us.Metadata.Annotations[tunneling.ProxyServiceAnnotation] = "squid:3128"
+
+			p := tunneling.NewPlugin(tunneling.WithStrictMode(true))
+			_, _, _, _, err := p.GeneratedResources(params, inClusters, nil, inRouteConfigurations, nil)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring(tunneling.ProxyServiceAnnotation))
+		})
+
+		It("should error without a CONNECT authority", func() {
+			us.HttpProxyHostname = nil
+
+			p := tunneling.NewPlugin(tunneling.WithStrictMode(true))
+			_, _, _, _, err := p.GeneratedResources(params, inClusters, nil, inRouteConfigurations, nil)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("no httpProxyHostname to CONNECT to"))
+		})
+
+		It("should error on settings which would modify the cluster of the service", func() {
+			us.HttpConnectSslConfig = &v1.UpstreamSslConfig{Sni: "squid.proxies"}
+
+			p := tunneling.NewPlugin(tunneling.WithStrictMode(true))
+			_, _, _, _, err := p.GeneratedResources(params, inClusters, nil, inRouteConfigurations, nil)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("cannot combine the %s annotation with httpConnectSslConfig", tunneling.ProxyServiceAnnotation))
+		})
+	})
+
+	Context("self cluster type", func() {
+
+		var (
+			edsUs *v1.Upstream
+		)
+
+		BeforeEach(func() {
+			// add a second tunneling upstream which serves its self cluster endpoints over EDS
+			edsUs, _, _ = addUpstream("eds-http-proxy-upstream", us.Metadata.Namespace, map[string]string{tunneling.SelfClusterTypeAnnotation: "eds"})
+		})
+
+		It("should generate static and EDS self clusters per upstream", func() {
+			us.Metadata.Annotations = map[string]string{tunneling.SelfClusterTypeAnnotation: "static"}
+
+			p := tunneling.NewPlugin()
+			p.Init(plugins.InitParams{Settings: &v1.Settings{}})
+			generatedClusters, generatedEndpoints, _, generatedListeners, err := p.GeneratedResources(params, inClusters, nil, inRouteConfigurations, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(generatedClusters).To(HaveLen(2))
+			Expect(generatedListeners).To(HaveLen(2))
+
+			staticCluster, edsCluster := generatedClusters[0], generatedClusters[1]
+
+			// the static self cluster inlines the pipe to the forwarding listener
+			Expect(staticCluster.GetType()).To(Equal(envoy_config_cluster_v3.Cluster_STATIC))
+			Expect(staticCluster.GetEdsClusterConfig()).To(BeNil())
+			staticPipe := staticCluster.GetLoadAssignment().GetEndpoints()[0].GetLbEndpoints()[0].GetEndpoint().GetAddress().GetPipe()
+			Expect(staticPipe).To(matchers.MatchProto(generatedListeners[0].GetAddress().GetPipe()))
+
+			// the EDS self cluster receives the pipe to the forwarding listener as a generated endpoint
+			Expect(edsCluster.GetType()).To(Equal(envoy_config_cluster_v3.Cluster_EDS))
+			Expect(edsCluster.GetEdsClusterConfig().GetEdsConfig().GetAds()).ToNot(BeNil())
+			Expect(edsCluster.GetLoadAssignment()).To(BeNil())
+			Expect(generatedEndpoints).To(HaveLen(1))
+			Expect(generatedEndpoints[0].GetClusterName()).To(Equal(edsCluster.GetName()))
+			edsPipe := generatedEndpoints[0].GetEndpoints()[0].GetLbEndpoints()[0].GetEndpoint().GetAddress().GetPipe()
+			Expect(edsPipe).To(matchers.MatchProto(generatedListeners[1].GetAddress().GetPipe()))
+		})
+
+		It("should error on an unknown self cluster type", func() {
+			edsUs.Metadata.Annotations[tunneling.SelfClusterTypeAnnotation] = "strict_dns"
+
+			p := tunneling.NewPlugin(tunneling.WithStrictMode(true))
+			_, _, _, _, err := p.GeneratedResources(params, inClusters, nil, inRouteConfigurations, nil)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring(tunneling.SelfClusterTypeAnnotation))
+		})
+	})
+
+	Context("happy eyeballs", func() {
+
+		BeforeEach(func() {
+			us.Metadata.Annotations = map[string]string{tunneling.HappyEyeballsAnnotation: "true"}
+		})
+
+		It("should resolve all address families on logical DNS clusters", func() {
+			inClusters[0].ClusterDiscoveryType = &envoy_config_cluster_v3.Cluster_Type{Type: envoy_config_cluster_v3.Cluster_LOGICAL_DNS}
+			inClusters[0].DnsLookupFamily = envoy_config_cluster_v3.Cluster_V4_ONLY
+
+			p := tunneling.NewPlugin()
+			generated, err := p.GenerateTunnelingResources(params, inClusters, inRouteConfigurations)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(inClusters[0].GetDnsLookupFamily()).To(Equal(envoy_config_cluster_v3.Cluster_ALL))
+			Expect(generated.ModifiedClusters.Has(inClusters[0].GetName())).To(BeTrue())
+		})
+
+		It("should leave the cluster untouched when disabled", func() {
+			us.Metadata.Annotations[tunneling.HappyEyeballsAnnotation] = "false"
+			inClusters[0].ClusterDiscoveryType = &envoy_config_cluster_v3.Cluster_Type{Type: envoy_config_cluster_v3.Cluster_STRICT_DNS}
+			inClusters[0].DnsLookupFamily = envoy_config_cluster_v3.Cluster_V4_ONLY
+
+			p := tunneling.NewPlugin()
+			_, err := p.GenerateTunnelingResources(params, inClusters, inRouteConfigurations)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(inClusters[0].GetDnsLookupFamily()).To(Equal(envoy_config_cluster_v3.Cluster_V4_ONLY))
+		})
+
+		DescribeTable("should error on clusters which are not resolved over logical DNS",
+			func(clusterType envoy_config_cluster_v3.Cluster_DiscoveryType) {
+				inClusters[0].ClusterDiscoveryType = &envoy_config_cluster_v3.Cluster_Type{Type: clusterType}
+
+				p := tunneling.NewPlugin(tunneling.WithStrictMode(true))
+				_, _, _, _, err := p.GeneratedResources(params, inClusters, nil, inRouteConfigurations, nil)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("requires a LOGICAL_DNS cluster, found %s cluster", clusterType))
+			},
+			// envoy load balances across the resolved addresses of strict DNS clusters rather than racing them
+			Entry("strict DNS", envoy_config_cluster_v3.Cluster_STRICT_DNS),
+			Entry("static", envoy_config_cluster_v3.Cluster_STATIC),
+		)
+	})
+
+	Context("tracing", func() {
+
+		var (
+			exporter *inMemoryExporter
+		)
+
+		BeforeEach(func() {
+			exporter = &inMemoryExporter{}
+			trace.RegisterExporter(exporter)
+			trace.ApplyConfig(trace.Config{DefaultSampler: trace.AlwaysSample()})
+
+			cfg, err := utils.MessageToAny(&envoyauth.UpstreamTlsContext{
+				CommonTlsContext: &envoyauth.CommonTlsContext{},
+				Sni:              httpProxyHostname,
+			})
+			Expect(err).ToNot(HaveOccurred())
+			inClusters[0].TransportSocket = &envoy_config_core_v3.TransportSocket{
+				ConfigType: &envoy_config_core_v3.TransportSocket_TypedConfig{
+					TypedConfig: cfg,
+				},
+			}
+			params.Ctx = context.Background()
+		})
+
+		AfterEach(func() {
+			trace.UnregisterExporter(exporter)
+			// restore the opencensus default so later tests are not affected
+			trace.ApplyConfig(trace.Config{DefaultSampler: trace.ProbabilitySampler(1e-4)})
+		})
+
+		It("should emit spans for the generation phases when enabled", func() {
+			p := tunneling.NewPlugin(tunneling.WithTracing(true))
+			_, _, _, _, err := p.GeneratedResources(params, inClusters, nil, inRouteConfigurations, nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			spans := exporter.spansByName()
+			Expect(spans).To(HaveKey(tunneling.GenerateSpanName))
+			Expect(spans).To(HaveKey(tunneling.ScanSpanName))
+			Expect(spans).To(HaveKey(tunneling.UpdateTlsContextSpanName))
+			Expect(spans).To(HaveKey(tunneling.MarshalSpanName))
+
+			// phases are nested in the generation span
+			generateSpan := spans[tunneling.GenerateSpanName]
+			scanSpan := spans[tunneling.ScanSpanName]
+			Expect(scanSpan.ParentSpanID).To(Equal(generateSpan.SpanID))
+			Expect(spans[tunneling.MarshalSpanName].ParentSpanID).To(Equal(scanSpan.SpanID))
+		})
+
+		It("should not emit spans when disabled", func() {
+			p := tunneling.NewPlugin()
+			_, _, _, _, err := p.GeneratedResources(params, inClusters, nil, inRouteConfigurations, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(exporter.spansByName()).To(BeEmpty())
+		})
+	})
+
+	Context("envoy validation", func() {
+
+		generateSnapshot := func() envoycache.Snapshot {
+			p := tunneling.NewPlugin()
+			generatedClusters, generatedEndpoints, _, generatedListeners, err := p.GeneratedResources(params, inClusters, nil, inRouteConfigurations, nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			var clusters, endpoints, listeners []envoycache.Resource
+			for _, c := range append(inClusters, generatedClusters...) {
+				clusters = append(clusters, resource.NewEnvoyResource(c))
+			}
+			for _, e := range generatedEndpoints {
+				endpoints = append(endpoints, resource.NewEnvoyResource(e))
+			}
+			for _, l := range generatedListeners {
+				listeners = append(listeners, resource.NewEnvoyResource(l))
+			}
+			return xds.NewSnapshotFromResources(
+				envoycache.NewResources("endpoints", endpoints),
+				envoycache.NewResources("clusters", clusters),
+				envoycache.NewResources("routes", nil),
+				envoycache.NewResources("listeners", listeners),
+			)
+		}
+
+		It("should accept valid generated resources", func() {
+			Expect(tunneling.ValidateGeneratedResources(generateSnapshot())).To(Succeed())
+		})
+
+		It("should report generated resources with fields envoy rejects", func() {
+			snap := generateSnapshot()
+			selfClusterName := tunneling.SelfClusterPrefix + inClusters[0].GetName()
+			selfCluster := snap.GetResources(types.ClusterTypeV3).Items[selfClusterName].ResourceProto().(*envoy_config_cluster_v3.Cluster)
+			selfCluster.ConnectTimeout = &duration.Duration{}
+
+			err := tunneling.ValidateGeneratedResources(snap)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("generated tunneling cluster %s would be rejected by envoy", selfClusterName))
+			Expect(err.Error()).To(ContainSubstring("ConnectTimeout"))
+		})
+
+		It("should report pipe paths which are too long", func() {
+			us.Metadata.Name = strings.Repeat("a", 100)
+			clusterName := translator.UpstreamToClusterName(us.Metadata.Ref())
+			inClusters[0].Name = clusterName
+			inRouteConfigurations[0].GetVirtualHosts()[0].GetRoutes()[0].GetRoute().ClusterSpecifier = &envoy_config_route_v3.RouteAction_Cluster{Cluster: clusterName}
+
+			err := tunneling.ValidateGeneratedResources(generateSnapshot())
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("generated tunneling cluster %s%s would be rejected by envoy", tunneling.SelfClusterPrefix, clusterName))
+			Expect(err.Error()).To(ContainSubstring("generated tunneling listener %s%s would be rejected by envoy", tunneling.SelfListenerPrefix, clusterName))
+			Expect(err.Error()).To(ContainSubstring("must be at most 107"))
+		})
+
+		It("should ignore resources which were not generated for tunneling", func() {
+			inClusters[0].ConnectTimeout = &duration.Duration{}
+			Expect(tunneling.ValidateGeneratedResources(generateSnapshot())).To(Succeed())
+		})
+	})
+
+	Context("generated resource validation", func() {
+
+		// routes the default tunneling upstream through a cluster whose pipe path is too long for envoy
+		useLongClusterName := func() string {
+			us.Metadata.Name = strings.Repeat("a", 100)
+			clusterName := translator.UpstreamToClusterName(us.Metadata.Ref())
+			inClusters[0].Name = clusterName
+			inRouteConfigurations[0].GetVirtualHosts()[0].GetRoutes()[0].GetRoute().ClusterSpecifier = &envoy_config_route_v3.RouteAction_Cluster{Cluster: clusterName}
+			return clusterName
+		}
+
+		It("should not validate the generated resources by default", func() {
+			useLongClusterName()
+
+			p := tunneling.NewPlugin()
+			generatedClusters, _, _, generatedListeners, err := p.GeneratedResources(params, inClusters, nil, inRouteConfigurations, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(generatedClusters).To(HaveLen(1))
+			Expect(generatedListeners).To(HaveLen(1))
+		})
+
+		It("should accept valid generated resources", func() {
+			p := tunneling.NewPlugin(tunneling.WithGeneratedResourceValidation(true))
+			generatedClusters, _, _, generatedListeners, err := p.GeneratedResources(params, inClusters, nil, inRouteConfigurations, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(generatedClusters).To(HaveLen(1))
+			Expect(generatedListeners).To(HaveLen(1))
+		})
+
+		It("should fail translation when the generated resources would be rejected by envoy", func() {
+			clusterName := useLongClusterName()
+
+			p := tunneling.NewPlugin(tunneling.WithGeneratedResourceValidation(true))
+			_, _, _, _, err := p.GeneratedResources(params, inClusters, nil, inRouteConfigurations, nil)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("generated tunneling cluster %s%s would be rejected by envoy", tunneling.SelfClusterPrefix, clusterName))
+			Expect(err.Error()).To(ContainSubstring("generated tunneling listener %s%s would be rejected by envoy", tunneling.SelfListenerPrefix, clusterName))
+		})
+
+		It("should report malformed generated resources", func() {
+			p := tunneling.NewPlugin()
+			generated, err := p.GenerateTunnelingResources(params, inClusters, inRouteConfigurations)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(tunneling.ValidateTunnelingResources(generated)).To(Succeed())
+
+			generated.Clusters[0].ConnectTimeout = &duration.Duration{}
+			generated.Listeners[0].FilterChains[0].Filters[0].Name = ""
+
+			err = tunneling.ValidateTunnelingResources(generated)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("generated tunneling cluster %s would be rejected by envoy", generated.Clusters[0].GetName()))
+			Expect(err.Error()).To(ContainSubstring("ConnectTimeout"))
+			Expect(err.Error()).To(ContainSubstring("generated tunneling listener %s would be rejected by envoy", generated.Listeners[0].GetName()))
+		})
+	})
+
+	Context("HTTP CONNECT proxy subject alt names", func() {
+
+		BeforeEach(func() {
+			us.HttpConnectSslConfig = &v1.UpstreamSslConfig{
+				SslSecrets: &v1.UpstreamSslConfig_SslFiles{
+					SslFiles: &v1.SSLFiles{RootCa: "root-ca"},
+				},
+				Sni:                  "host.com",
+				VerifySubjectAltName: []string{"proxy.example.com", "spiffe://cluster.local/ns/proxy/sa/proxy"},
+			}
+		})
+
+		It("should verify the subject alt names of the proxy certificate", func() {
+			p := tunneling.NewPlugin()
+			_, _, _, _, err := p.GeneratedResources(params, inClusters, nil, inRouteConfigurations, nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			connectTlsContext := utils.MustAnyToMessage(inClusters[0].GetTransportSocket().GetTypedConfig()).(*envoyauth.UpstreamTlsContext)
+			validationContext := connectTlsContext.GetCommonTlsContext().GetValidationContext()
+			Expect(validationContext.GetMatchSubjectAltNames()).To(HaveLen(2))
+			Expect(validationContext.GetMatchSubjectAltNames()[0].GetExact()).To(Equal("proxy.example.com"))
+			Expect(validationContext.GetMatchSubjectAltNames()[1].GetExact()).To(Equal("spiffe://cluster.local/ns/proxy/sa/proxy"))
+		})
+
+		DescribeTable("should error on invalid subject alt names",
+			func(san string, expectedErr string) {
+				us.HttpConnectSslConfig.VerifySubjectAltName = append(us.HttpConnectSslConfig.VerifySubjectAltName, san)
+
+				p := tunneling.NewPlugin(tunneling.WithStrictMode(true))
+				_, _, _, _, err := p.GeneratedResources(params, inClusters, nil, inRouteConfigurations, nil)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring(expectedErr))
+			},
+			Entry("empty", "", "must not be empty"),
+			Entry("whitespace", "proxy .example.com", "must not contain whitespace"),
+			Entry("duplicate", "proxy.example.com", "duplicate entry"),
+		)
+	})
+
+	Context("HTTP CONNECT proxy protocol consistency", func() {
+
+		DescribeTable("should accept consistent protocol configurations",
+			func(useHttp2 bool, sslConfig *v1.UpstreamSslConfig) {
+				us.UseHttp2 = &wrappers.BoolValue{Value: useHttp2}
+				us.HttpConnectSslConfig = sslConfig
+
+				p := tunneling.NewPlugin(tunneling.WithStrictMode(true))
+				_, _, _, _, err := p.GeneratedResources(params, inClusters, nil, inRouteConfigurations, nil)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(messages).To(BeEmpty())
+			},
+			Entry("HTTP/1.1 without TLS", false, nil),
+			Entry("HTTP/2 without TLS", true, nil),
+			Entry("HTTP/1.1 over TLS", false, &v1.UpstreamSslConfig{Sni: "proxy.example.com"}),
+			Entry("HTTP/1.1 over TLS with ALPN", false, &v1.UpstreamSslConfig{Sni: "proxy.example.com", AlpnProtocols: []string{"http/1.1"}}),
+			Entry("HTTP/2 over TLS with ALPN", true, &v1.UpstreamSslConfig{Sni: "proxy.example.com", AlpnProtocols: []string{"h2", "http/1.1"}}),
+		)
+
+		inconsistentProtocolEntries := []TableEntry{
+			Entry("HTTP/2 over TLS without ALPN", true, &v1.UpstreamSslConfig{Sni: "proxy.example.com"},
+				"useHttp2 requires the h2 ALPN protocol"),
+			Entry("HTTP/2 over TLS without h2 ALPN", true, &v1.UpstreamSslConfig{Sni: "proxy.example.com", AlpnProtocols: []string{"http/1.1"}},
+				"useHttp2 requires the h2 ALPN protocol"),
+			Entry("HTTP/1.1 over TLS with h2 ALPN", false, &v1.UpstreamSslConfig{Sni: "proxy.example.com", AlpnProtocols: []string{"h2"}},
+				"the h2 ALPN protocol in httpConnectSslConfig.alpnProtocols requires useHttp2"),
+			Entry("SNI with a port", false, &v1.UpstreamSslConfig{Sni: "proxy.example.com:443"},
+				"must not contain a port"),
+			Entry("SNI with an IPv4 address", false, &v1.UpstreamSslConfig{Sni: "10.0.0.1"},
+				"must be a host name, not an IP address"),
+			Entry("SNI with an IPv6 address", false, &v1.UpstreamSslConfig{Sni: "::1"},
+				"must be a host name, not an IP address"),
+		}
+
+		DescribeTable("should warn about inconsistent protocol configurations",
+			func(useHttp2 bool, sslConfig *v1.UpstreamSslConfig, expectedWarning string) {
+				us.UseHttp2 = &wrappers.BoolValue{Value: useHttp2}
+				us.HttpConnectSslConfig = sslConfig
+
+				p := tunneling.NewPlugin()
+				generatedClusters, _, _, generatedListeners, err := p.GeneratedResources(params, inClusters, nil, inRouteConfigurations, nil)
+				Expect(err).ToNot(HaveOccurred())
+				// the upstream is still tunneled
+				Expect(generatedClusters).To(HaveLen(1))
+				Expect(generatedListeners).To(HaveLen(1))
+				warnings := messagesFor(us.GetMetadata().Ref())
+				Expect(warnings).To(HaveLen(1))
+				Expect(warnings[0]).To(ContainSubstring("inconsistent HTTP CONNECT proxy protocol configuration"))
+				Expect(warnings[0]).To(ContainSubstring(expectedWarning))
+			},
+			inconsistentProtocolEntries...,
+		)
+
+		DescribeTable("should error on inconsistent protocol configurations in strict mode",
+			func(useHttp2 bool, sslConfig *v1.UpstreamSslConfig, expectedErr string) {
+				us.UseHttp2 = &wrappers.BoolValue{Value: useHttp2}
+				us.HttpConnectSslConfig = sslConfig
+
+				p := tunneling.NewPlugin(tunneling.WithStrictMode(true))
+				_, _, _, _, err := p.GeneratedResources(params, inClusters, nil, inRouteConfigurations, nil)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("inconsistent HTTP CONNECT proxy protocol configuration"))
+				Expect(err.Error()).To(ContainSubstring(expectedErr))
+			},
+			inconsistentProtocolEntries...,
+		)
+	})
+
+	Context("HTTP CONNECT proxy protocol auto-detection", func() {
+
+		getHttpProtocolOptions := func(cluster *envoy_config_cluster_v3.Cluster) *envoy_extensions_upstreams_http_v3.HttpProtocolOptions {
+			typedConfig, ok := cluster.GetTypedExtensionProtocolOptions()["envoy.extensions.upstreams.http.v3.HttpProtocolOptions"]
+			if !ok {
+				return nil
+			}
+			return utils.MustAnyToMessage(typedConfig).(*envoy_extensions_upstreams_http_v3.HttpProtocolOptions)
+		}
+
+		BeforeEach(func() {
+			us.HttpConnectSslConfig = &v1.UpstreamSslConfig{Sni: "proxy.example.com", AlpnProtocols: []string{"h2", "http/1.1"}}
+		})
+
+		It("should not auto-detect the protocol by default", func() {
+			us.UseHttp2 = &wrappers.BoolValue{Value: true}
+
+			p := tunneling.NewPlugin()
+			_, _, _, _, err := p.GeneratedResources(params, inClusters, nil, inRouteConfigurations, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(getHttpProtocolOptions(inClusters[0])).To(BeNil())
+		})
+
+		It("should apply the auto-detect protocol options to the cluster reaching the HTTP CONNECT proxy", func() {
+			us.Metadata.Annotations = map[string]string{tunneling.HttpProtocolAutoDetectAnnotation: "true"}
+
+			p := tunneling.NewPlugin()
+			generated, err := p.GenerateTunnelingResources(params, inClusters, inRouteConfigurations)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(generated.ModifiedClusters.Has(inClusters[0].GetName())).To(BeTrue())
+			Expect(getHttpProtocolOptions(inClusters[0])).To(matchers.MatchProto(&envoy_extensions_upstreams_http_v3.HttpProtocolOptions{
+				UpstreamProtocolOptions: &envoy_extensions_upstreams_http_v3.HttpProtocolOptions_AutoConfig{
+					AutoConfig: &envoy_extensions_upstreams_http_v3.HttpProtocolOptions_AutoHttpConfig{
+						HttpProtocolOptions:  &envoy_config_core_v3.Http1ProtocolOptions{},
+						Http2ProtocolOptions: &envoy_config_core_v3.Http2ProtocolOptions{},
+					},
+				},
+			}))
+		})
+
+		It("should move the protocol options set from the connection config into the auto-detect protocol options", func() {
+			us.Metadata.Annotations = map[string]string{tunneling.HttpProtocolAutoDetectAnnotation: "true"}
+			us.ConnectionConfig = &v1.ConnectionConfig{
+				CommonHttpProtocolOptions: &protocol.HttpProtocolOptions{
+					IdleTimeout: &duration.Duration{Seconds: 30},
+				},
+				Http1ProtocolOptions: &protocol.Http1ProtocolOptions{
+					EnableTrailers: true,
+				},
+			}
+			Expect(upstreamconn.NewPlugin().ProcessUpstream(params, us, inClusters[0])).To(Succeed())
+			Expect(inClusters[0].GetCommonHttpProtocolOptions()).ToNot(BeNil())
+			Expect(inClusters[0].GetHttpProtocolOptions()).ToNot(BeNil())
+
+			p := tunneling.NewPlugin()
+			_, err := p.GenerateTunnelingResources(params, inClusters, inRouteConfigurations)
+			Expect(err).ToNot(HaveOccurred())
+			// envoy rejects clusters which set both the typed and the deprecated protocol options
+			Expect(inClusters[0].GetCommonHttpProtocolOptions()).To(BeNil())
+			Expect(inClusters[0].GetHttpProtocolOptions()).To(BeNil())
+			Expect(getHttpProtocolOptions(inClusters[0])).To(matchers.MatchProto(&envoy_extensions_upstreams_http_v3.HttpProtocolOptions{
+				CommonHttpProtocolOptions: &envoy_config_core_v3.HttpProtocolOptions{
+					IdleTimeout: &duration.Duration{Seconds: 30},
+				},
+				UpstreamProtocolOptions: &envoy_extensions_upstreams_http_v3.HttpProtocolOptions_AutoConfig{
+					AutoConfig: &envoy_extensions_upstreams_http_v3.HttpProtocolOptions_AutoHttpConfig{
+						HttpProtocolOptions:  &envoy_config_core_v3.Http1ProtocolOptions{EnableTrailers: true},
+						Http2ProtocolOptions: &envoy_config_core_v3.Http2ProtocolOptions{},
+					},
+				},
+			}))
+		})
+
+		DescribeTable("should error when auto-detection is not paired with ALPN advertising both protocols",
+			func(useHttp2 bool, sslConfig *v1.UpstreamSslConfig, expectedErr string) {
+				us.Metadata.Annotations = map[string]string{tunneling.HttpProtocolAutoDetectAnnotation: "true"}
+				us.UseHttp2 = &wrappers.BoolValue{Value: useHttp2}
+				us.HttpConnectSslConfig = sslConfig
+
+				p := tunneling.NewPlugin(tunneling.WithStrictMode(true))
+				_, _, _, _, err := p.GeneratedResources(params, inClusters, nil, inRouteConfigurations, nil)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring(expectedErr))
+			},
+			Entry("without TLS", false, nil,
+				"protocol auto-detection requires httpConnectSslConfig"),
+			Entry("without ALPN", false, &v1.UpstreamSslConfig{Sni: "proxy.example.com"},
+				"requires both the h2 and http/1.1 ALPN protocols"),
+			Entry("with only h2 ALPN", false, &v1.UpstreamSslConfig{Sni: "proxy.example.com", AlpnProtocols: []string{"h2"}},
+				"requires both the h2 and http/1.1 ALPN protocols"),
+			Entry("with only http/1.1 ALPN", false, &v1.UpstreamSslConfig{Sni: "proxy.example.com", AlpnProtocols: []string{"http/1.1"}},
+				"requires both the h2 and http/1.1 ALPN protocols"),
+			Entry("with useHttp2", true, &v1.UpstreamSslConfig{Sni: "proxy.example.com", AlpnProtocols: []string{"h2", "http/1.1"}},
+				"cannot be combined with useHttp2"),
+		)
+
+		It("should error on an invalid annotation value", func() {
+			us.Metadata.Annotations = map[string]string{tunneling.HttpProtocolAutoDetectAnnotation: "sometimes"}
+
+			p := tunneling.NewPlugin(tunneling.WithStrictMode(true))
+			_, _, _, _, err := p.GeneratedResources(params, inClusters, nil, inRouteConfigurations, nil)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring(tunneling.HttpProtocolAutoDetectAnnotation))
+		})
+	})
+
+	Context("audit logging", func() {
+
+		var (
+			logs *observer.ObservedLogs
+		)
+
+		BeforeEach(func() {
+			var core zapcore.Core
+			core, logs = observer.New(zap.InfoLevel)
+			params.Ctx = contextutils.WithExistingLogger(context.Background(), zap.New(core).Sugar())
+		})
+
+		It("should log the CONNECT hostname of tunneling upstreams when enabled", func() {
+			p := tunneling.NewPlugin(tunneling.WithAuditLogging(true))
+			_, _, _, _, err := p.GeneratedResources(params, inClusters, nil, inRouteConfigurations, nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			entries := logs.FilterMessage("tunneling upstream targets CONNECT hostname").All()
+			Expect(entries).To(HaveLen(1))
+			Expect(entries[0].Level).To(Equal(zap.InfoLevel))
+			Expect(entries[0].ContextMap()).To(Equal(map[string]interface{}{
+				"upstream":        us.GetMetadata().Ref().Key(),
+				"connectHostname": httpProxyHostname,
+			}))
+		})
+
+		It("should not log the CONNECT hostname by default", func() {
+			p := tunneling.NewPlugin()
+			_, _, _, _, err := p.GeneratedResources(params, inClusters, nil, inRouteConfigurations, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(logs.FilterMessage("tunneling upstream targets CONNECT hostname").Len()).To(BeZero())
+		})
+	})
+
+	Context("strict mode", func() {
+
+		BeforeEach(func() {
+			// the secret is not in the snapshot
+			us.HttpConnectSslConfig = &v1.UpstreamSslConfig{
+				SslSecrets: &v1.UpstreamSslConfig_SecretRef{
+					SecretRef: &core.ResourceRef{Name: "missing-secret", Namespace: "gloo-system"},
+				},
+			}
+		})
+
+		It("should skip the upstream and report a missing secret by default", func() {
+			p := tunneling.NewPlugin()
+			generatedClusters, _, _, generatedListeners, err := p.GeneratedResources(params, inClusters, nil, inRouteConfigurations, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(generatedClusters).To(BeEmpty())
+			Expect(generatedListeners).To(BeEmpty())
+			// the route and cluster of the upstream are left untouched
+			Expect(inRouteConfigurations[0].GetVirtualHosts()[0].GetRoutes()[0].GetRoute().GetCluster()).To(Equal(inClusters[0].GetName()))
+			Expect(inClusters[0].GetTransportSocket()).To(BeNil())
+			warnings := messagesFor(us.GetMetadata().Ref())
+			Expect(warnings).To(HaveLen(1))
+			Expect(warnings[0]).To(ContainSubstring("failed to resolve the HTTP CONNECT TLS configuration of tunneling upstream %s", us.GetMetadata().Ref().Key()))
+		})
+
+		DescribeTable("should skip and report any upstream whose tunneling cannot be resolved by default",
+			func(configure func(), expectedWarning string) {
+				us.HttpConnectSslConfig = nil
+				configure()
+
+				p := tunneling.NewPlugin()
+				generatedClusters, _, _, generatedListeners, err := p.GeneratedResources(params, inClusters, nil, inRouteConfigurations, nil)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(generatedClusters).To(BeEmpty())
+				Expect(generatedListeners).To(BeEmpty())
+				Expect(inRouteConfigurations[0].GetVirtualHosts()[0].GetRoutes()[0].GetRoute().GetCluster()).To(Equal(inClusters[0].GetName()))
+				Expect(inClusters[0].GetDnsLookupFamily()).To(Equal(envoy_config_cluster_v3.Cluster_AUTO))
+				warnings := messagesFor(us.GetMetadata().Ref())
+				Expect(warnings).To(HaveLen(1))
+				Expect(warnings[0]).To(ContainSubstring(expectedWarning))
+			},
+			Entry("invalid annotation", func() {
+				us.Metadata.Annotations = map[string]string{tunneling.MaxSessionKeysAnnotation: "-1"}
+			}, tunneling.MaxSessionKeysAnnotation),
+			Entry("proxy service not found", func() {
+				us.Metadata.Annotations = map[string]string{tunneling.ProxyServiceAnnotation: "proxies/squid:3128"}
+			}, "proxies/squid:3128 which was not found"),
+			Entry("happy eyeballs on a cluster which is not resolved over logical DNS", func() {
+				us.Metadata.Annotations = map[string]string{tunneling.HappyEyeballsAnnotation: "true"}
+				inClusters[0].ClusterDiscoveryType = &envoy_config_cluster_v3.Cluster_Type{Type: envoy_config_cluster_v3.Cluster_STRICT_DNS}
+			}, "requires a LOGICAL_DNS cluster"),
+		)
+
+		It("should still tunnel the other upstreams", func() {
+			validUs, validRoute, validCluster := addUpstream("valid-http-proxy-upstream", us.Metadata.Namespace, nil)
+			validUs.HttpConnectSslConfig = nil
+
+			p := tunneling.NewPlugin()
+			generatedClusters, _, _, generatedListeners, err := p.GeneratedResources(params, inClusters, nil, inRouteConfigurations, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(generatedClusters).To(HaveLen(1))
+			Expect(generatedClusters[0].GetName()).To(Equal(tunneling.SelfClusterPrefix + validCluster.GetName()))
+			Expect(generatedListeners).To(HaveLen(1))
+			Expect(validRoute.GetRoute().GetCluster()).To(Equal(tunneling.SelfClusterPrefix + validCluster.GetName()))
+			Expect(messagesFor(validUs.GetMetadata().Ref())).To(BeEmpty())
+			Expect(messagesFor(us.GetMetadata().Ref())).To(HaveLen(1))
+		})
+
+		It("should error on a missing secret", func() {
+			p := tunneling.NewPlugin(tunneling.WithStrictMode(true))
+			_, _, _, _, err := p.GeneratedResources(params, inClusters, nil, inRouteConfigurations, nil)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("failed to resolve the HTTP CONNECT TLS configuration of tunneling upstream %s", us.GetMetadata().Ref().Key()))
+			Expect(err.Error()).To(ContainSubstring("missing-secret"))
+		})
+
+		It("should leave every route and cluster untouched when a later upstream fails", func() {
+			// the transport socket of a tunneled cluster is removed, which makes an update of the clusters visible
+			inClusters[0].TransportSocket = &envoy_config_core_v3.TransportSocket{Name: "envoy.transport_sockets.tls"}
+			// the route to the default upstream is processed first, and the upstream can be tunneled
+			missingSecretSslConfig := us.HttpConnectSslConfig
+			us.HttpConnectSslConfig = nil
+			failingUs, _, _ := addUpstream("failing-http-proxy-upstream", us.Metadata.Namespace, nil)
+			failingUs.HttpConnectSslConfig = missingSecretSslConfig
+
+			expectedRouteConfig := proto.Clone(inRouteConfigurations[0])
+			var expectedClusters []proto.Message
+			for _, cluster := range inClusters {
+				expectedClusters = append(expectedClusters, proto.Clone(cluster))
+			}
+
+			p := tunneling.NewPlugin(tunneling.WithStrictMode(true))
+			_, _, _, _, err := p.GeneratedResources(params, inClusters, nil, inRouteConfigurations, nil)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("failed to resolve the HTTP CONNECT TLS configuration of tunneling upstream %s", failingUs.GetMetadata().Ref().Key()))
+			Expect(inRouteConfigurations[0]).To(matchers.MatchProto(expectedRouteConfig))
+			Expect(inClusters).To(HaveLen(len(expectedClusters)))
+			for i, cluster := range inClusters {
+				Expect(cluster).To(matchers.MatchProto(expectedClusters[i]))
+			}
+		})
+	})
+
+	Context("tenants", func() {
+
+		It("should isolate the listener endpoints of each tenant", func() {
+			// the same upstream is tunneled for each tenant, through a proxy of its own
+			pipeOf := func(tenant string) string {
+				us.Metadata.Annotations = map[string]string{tunneling.TenantAnnotation: tenant}
+				clusters := []*envoy_config_cluster_v3.Cluster{proto.Clone(inClusters[0]).(*envoy_config_cluster_v3.Cluster)}
+				routeConfigs := []*envoy_config_route_v3.RouteConfiguration{proto.Clone(inRouteConfigurations[0]).(*envoy_config_route_v3.RouteConfiguration)}
+
+				p := tunneling.NewPlugin()
+				generatedClusters, _, _, generatedListeners, err := p.GeneratedResources(params, clusters, nil, routeConfigs, nil)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(generatedClusters).To(HaveLen(1))
+				Expect(generatedListeners).To(HaveLen(1))
+				listenerPipe := generatedListeners[0].GetAddress().GetPipe().GetPath()
+				selfClusterPipe := generatedClusters[0].GetLoadAssignment().GetEndpoints()[0].GetLbEndpoints()[0].GetEndpoint().GetAddress().GetPipe().GetPath()
+				Expect(selfClusterPipe).To(Equal(listenerPipe), "generated cluster should route to its own listener")
+				return listenerPipe
+			}
+
+			cluster := inClusters[0].GetName()
+			Expect(pipeOf("tenant-a")).To(Equal("@/tenant-a/" + cluster))
+			Expect(pipeOf("tenant-b")).To(Equal("@/tenant-b/" + cluster))
+		})
+
+		Context("long cluster names", func() {
+
+			var (
+				longUs *v1.Upstream
+			)
+
+			BeforeEach(func() {
+				// the pipe of the cluster fits in sockaddr_un on its own, at 104 characters, but not once scoped to a tenant
+				longUs, _, _ = addUpstream(strings.Repeat("a", 90), "gloo-system", nil)
+			})
+
+			It("should tunnel upstreams whose pipe fits", func() {
+				p := tunneling.NewPlugin(tunneling.WithStrictMode(true))
+				_, _, _, generatedListeners, err := p.GeneratedResources(params, inClusters, nil, inRouteConfigurations, nil)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(generatedListeners).To(HaveLen(2))
+				Expect(generatedListeners[1].GetAddress().GetPipe().GetPath()).To(HaveLen(104))
+			})
+
+			It("should error when the tenant makes the pipe too long", func() {
+				longUs.Metadata.Annotations = map[string]string{tunneling.TenantAnnotation: "tenant-a"}
+
+				p := tunneling.NewPlugin(tunneling.WithStrictMode(true))
+				_, _, _, _, err := p.GeneratedResources(params, inClusters, nil, inRouteConfigurations, nil)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("cannot tunnel upstream %s", longUs.GetMetadata().Ref().Key()))
+				Expect(err.Error()).To(ContainSubstring("is 113 characters long, must be at most 107"))
+			})
+
+			It("should skip and report the upstream by default", func() {
+				longUs.Metadata.Annotations = map[string]string{tunneling.TenantAnnotation: "tenant-a"}
+
+				p := tunneling.NewPlugin()
+				_, _, _, generatedListeners, err := p.GeneratedResources(params, inClusters, nil, inRouteConfigurations, nil)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(generatedListeners).To(HaveLen(1))
+				Expect(messagesFor(longUs.GetMetadata().Ref())).To(ConsistOf(ContainSubstring("must be at most 107")))
+			})
+		})
+
+		It("should skip and report upstreams which would share a pipe", func() {
+			// both upstreams map to the cluster a_b_c
+			collidingUs, _, _ := addUpstream("a_b", "c", nil)
+			addUpstream("a", "b_c", nil)
+
+			p := tunneling.NewPlugin()
+			generatedClusters, _, _, generatedListeners, err := p.GeneratedResources(params, inClusters, nil, inRouteConfigurations, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(generatedClusters).To(HaveLen(1))
+			Expect(generatedListeners).To(HaveLen(1))
+			Expect(generatedListeners[0].GetAddress().GetPipe().GetPath()).To(Equal("@/" + inClusters[0].GetName()))
+			for _, route := range inRouteConfigurations[0].GetVirtualHosts()[0].GetRoutes()[1:] {
+				Expect(route.GetRoute().GetCluster()).To(Equal("a_b_c"))
+			}
+			warnings := messagesFor(collidingUs.GetMetadata().Ref())
+			Expect(warnings).To(HaveLen(1))
+			Expect(warnings[0]).To(ContainSubstring("tunneling upstreams c.a_b and b_c.a would share the pipe @/a_b_c"))
+		})
+
+		It("should error when upstreams would share a pipe in strict mode", func() {
+			addUpstream("a_b", "c", nil)
+			addUpstream("a", "b_c", nil)
+
+			p := tunneling.NewPlugin(tunneling.WithStrictMode(true))
+			_, _, _, _, err := p.GeneratedResources(params, inClusters, nil, inRouteConfigurations, nil)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("tunneling upstreams c.a_b and b_c.a would share the pipe @/a_b_c"))
+		})
+
+		It("should error on an invalid tenant", func() {
+			us.Metadata.Annotations = map[string]string{tunneling.TenantAnnotation: "Tenant/A"}
+
+			p := tunneling.NewPlugin(tunneling.WithStrictMode(true))
+			_, _, _, _, err := p.GeneratedResources(params, inClusters, nil, inRouteConfigurations, nil)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring(tunneling.TenantAnnotation))
+		})
+	})
+
+	Context("access log flush interval", func() {
+
+		It("should only flush access logs on close by default", func() {
+			p := tunneling.NewPlugin()
+			_, _, _, generatedListeners, err := p.GeneratedResources(params, inClusters, nil, inRouteConfigurations, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(getTcpProxy(generatedListeners[0]).GetAccessLogFlushInterval()).To(BeNil())
+		})
+
+		It("should set the flush interval on the generated TCP proxy", func() {
+			us.Metadata.Annotations = map[string]string{tunneling.AccessLogFlushIntervalAnnotation: "1m30s"}
+
+			p := tunneling.NewPlugin()
+			_, _, _, generatedListeners, err := p.GeneratedResources(params, inClusters, nil, inRouteConfigurations, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(getTcpProxy(generatedListeners[0]).GetAccessLogFlushInterval()).To(matchers.MatchProto(&duration.Duration{Seconds: 90}))
+		})
+
+		DescribeTable("should error on invalid flush intervals",
+			func(interval string) {
+				us.Metadata.Annotations = map[string]string{tunneling.AccessLogFlushIntervalAnnotation: interval}
+
+				p := tunneling.NewPlugin(tunneling.WithStrictMode(true))
+				_, _, _, _, err := p.GeneratedResources(params, inClusters, nil, inRouteConfigurations, nil)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring(tunneling.AccessLogFlushIntervalAnnotation))
+			},
+			Entry("not a duration", "often"),
+			Entry("zero", "0s"),
+			Entry("below envoy's minimum", "500us"),
+		)
+	})
+
+	Context("CONNECT hostname case", func() {
+
+		BeforeEach(func() {
+			us.HttpProxyHostname = &wrappers.StringValue{Value: "Proxy.Corp.COM:3128"}
+		})
+
+		It("should lowercase the CONNECT hostname by default", func() {
+			p := tunneling.NewPlugin()
+			_, _, _, generatedListeners, err := p.GeneratedResources(params, inClusters, nil, inRouteConfigurations, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(getTcpProxy(generatedListeners[0]).GetTunnelingConfig().GetHostname()).To(Equal("proxy.corp.com:3128"))
+		})
+
+		It("should preserve the case of the CONNECT hostname", func() {
+			p := tunneling.NewPlugin(tunneling.WithPreservedHostnameCase(true))
+			_, _, _, generatedListeners, err := p.GeneratedResources(params, inClusters, nil, inRouteConfigurations, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(getTcpProxy(generatedListeners[0]).GetTunnelingConfig().GetHostname()).To(Equal("Proxy.Corp.COM:3128"))
+		})
+
+		DescribeTable("should error on invalid CONNECT hostnames",
+			func(preserveCase bool, hostname string) {
+				us.HttpProxyHostname = &wrappers.StringValue{Value: hostname}
+
+				p := tunneling.NewPlugin(tunneling.WithPreservedHostnameCase(preserveCase), tunneling.WithStrictMode(true))
+				_, _, _, _, err := p.GeneratedResources(params, inClusters, nil, inRouteConfigurations, nil)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("invalid CONNECT hostname %q", hostname))
+			},
+			Entry("invalid host, lowercased", false, "Proxy_Corp.com:3128"),
+			Entry("invalid host, case preserved", true, "Proxy_Corp.com:3128"),
+			Entry("trailing dot", false, "proxy.corp.com.:3128"),
+			Entry("invalid port, lowercased", false, "Proxy.Corp.com:http"),
+			Entry("invalid port, case preserved", true, "Proxy.Corp.com:http"),
+			Entry("missing host", true, ":3128"),
+		)
+
+		DescribeTable("should warn about CONNECT hosts which are not DNS-1123 names by default",
+			func(hostname string) {
+				us.HttpProxyHostname = &wrappers.StringValue{Value: hostname}
+
+				p := tunneling.NewPlugin()
+				_, _, _, generatedListeners, err := p.GeneratedResources(params, inClusters, nil, inRouteConfigurations, nil)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(getTcpProxy(generatedListeners[0]).GetTunnelingConfig().GetHostname()).To(Equal(hostname))
+				warnings := messagesFor(us.GetMetadata().Ref())
+				Expect(warnings).To(HaveLen(1))
+				Expect(warnings[0]).To(ContainSubstring("invalid CONNECT hostname %q", hostname))
+			},
+			Entry("underscore", "proxy_corp.com:3128"),
+			Entry("trailing dot", "proxy.corp.com.:3128"),
+		)
+	})
+
+	Context("resources by upstream", func() {
+
+		var (
+			edsUs *v1.Upstream
+		)
+
+		BeforeEach(func() {
+			// add a second tunneling upstream which serves its self cluster endpoints over EDS
+			edsUs, _, _ = addUpstream("eds-http-proxy-upstream", us.Metadata.Namespace, map[string]string{tunneling.SelfClusterTypeAnnotation: "eds"})
+
+			// the transport socket of the first cluster is moved to its self cluster
+			inClusters[0].TransportSocket = &envoy_config_core_v3.TransportSocket{Name: "envoy.transport_sockets.tls"}
+		})
+
+		It("should group the generated resources by the upstream they were generated for", func() {
+			usCluster := translator.UpstreamToClusterName(us.Metadata.Ref())
+			edsUsCluster := translator.UpstreamToClusterName(edsUs.Metadata.Ref())
+
+			p := tunneling.NewPlugin()
+			byUpstream, err := p.GeneratedResourcesByUpstream(params, inClusters, inRouteConfigurations)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(byUpstream).To(HaveLen(2))
+
+			usResources := byUpstream[us.Metadata.Ref().Key()]
+			Expect(usResources).ToNot(BeNil())
+			Expect(usResources.Clusters).To(HaveLen(1))
+			Expect(usResources.Clusters[0].GetName()).To(Equal(tunneling.SelfClusterPrefix + usCluster))
+			Expect(usResources.Endpoints).To(BeEmpty())
+			Expect(usResources.Listeners).To(HaveLen(1))
+			Expect(usResources.Listeners[0].GetName()).To(Equal(tunneling.SelfListenerPrefix + usCluster))
+			Expect(usResources.ModifiedClusters.List()).To(ConsistOf(usCluster))
+
+			edsUsResources := byUpstream[edsUs.Metadata.Ref().Key()]
+			Expect(edsUsResources).ToNot(BeNil())
+			Expect(edsUsResources.Clusters).To(HaveLen(1))
+			Expect(edsUsResources.Clusters[0].GetName()).To(Equal(tunneling.SelfClusterPrefix + edsUsCluster))
+			Expect(edsUsResources.Endpoints).To(HaveLen(1))
+			Expect(edsUsResources.Endpoints[0].GetClusterName()).To(Equal(tunneling.SelfClusterPrefix + edsUsCluster))
+			Expect(edsUsResources.Listeners).To(HaveLen(1))
+			Expect(edsUsResources.Listeners[0].GetName()).To(Equal(tunneling.SelfListenerPrefix + edsUsCluster))
+			Expect(edsUsResources.ModifiedClusters.List()).To(BeEmpty())
+		})
+
+		It("should return no resources when no route targets a tunneling upstream", func() {
+			inRouteConfigurations = nil
+
+			p := tunneling.NewPlugin()
+			byUpstream, err := p.GeneratedResourcesByUpstream(params, inClusters, inRouteConfigurations)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(byUpstream).To(BeEmpty())
+		})
+	})
+
+	Context("routes without a cluster action", func() {
+
+		DescribeTable("should leave the route untouched",
+			func(setAction func(rt *envoy_config_route_v3.Route)) {
+				setAction(inRouteConfigurations[0].VirtualHosts[0].Routes[0])
+				expectedRoute := proto.Clone(inRouteConfigurations[0].VirtualHosts[0].Routes[0])
+				expectedCluster := proto.Clone(inClusters[0])
+
+				p := tunneling.NewPlugin()
+				generatedClusters, _, _, generatedListeners, err := p.GeneratedResources(params, inClusters, nil, inRouteConfigurations, nil)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(generatedClusters).To(BeEmpty())
+				Expect(generatedListeners).To(BeEmpty())
+				Expect(inRouteConfigurations[0].VirtualHosts[0].Routes[0]).To(matchers.MatchProto(expectedRoute))
+				Expect(inClusters[0]).To(matchers.MatchProto(expectedCluster))
+			},
+			Entry("redirect", func(rt *envoy_config_route_v3.Route) {
+				rt.Action = &envoy_config_route_v3.Route_Redirect{
+					Redirect: &envoy_config_route_v3.RedirectAction{HostRedirect: "example.com"},
+				}
+			}),
+			Entry("direct response", func(rt *envoy_config_route_v3.Route) {
+				rt.Action = &envoy_config_route_v3.Route_DirectResponse{
+					DirectResponse: &envoy_config_route_v3.DirectResponseAction{Status: 200},
+				}
+			}),
+		)
+
+		It("should still tunnel the cluster routes of the same virtual host", func() {
+			redirectRoute := proto.Clone(inRouteConfigurations[0].VirtualHosts[0].Routes[0]).(*envoy_config_route_v3.Route)
+			redirectRoute.Name = "redirectroute"
+			redirectRoute.Action = &envoy_config_route_v3.Route_Redirect{
+				Redirect: &envoy_config_route_v3.RedirectAction{HostRedirect: "example.com"},
+			}
+			inRouteConfigurations[0].VirtualHosts[0].Routes = append([]*envoy_config_route_v3.Route{redirectRoute}, inRouteConfigurations[0].VirtualHosts[0].Routes...)
+
+			p := tunneling.NewPlugin()
+			generatedClusters, _, _, generatedListeners, err := p.GeneratedResources(params, inClusters, nil, inRouteConfigurations, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(generatedClusters).To(HaveLen(1))
+			Expect(generatedListeners).To(HaveLen(1))
+			Expect(inRouteConfigurations[0].VirtualHosts[0].Routes[0].GetRedirect().GetHostRedirect()).To(Equal("example.com"))
+			Expect(inRouteConfigurations[0].VirtualHosts[0].Routes[1].GetRoute().GetCluster()).To(Equal(generatedClusters[0].GetName()))
+		})
+	})
+
+	Context("connection pool per downstream", func() {
+
+		It("should share the connection pool of the generated cluster by default", func() {
+			p := tunneling.NewPlugin()
+			generatedClusters, _, _, _, err := p.GeneratedResources(params, inClusters, nil, inRouteConfigurations, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(generatedClusters[0].GetConnectionPoolPerDownstreamConnection()).To(BeFalse())
+		})
+
+		It("should isolate the connection pool of each downstream connection on the generated cluster", func() {
+			us.Metadata.Annotations = map[string]string{tunneling.ConnectionPoolPerDownstreamAnnotation: "true"}
+
+			p := tunneling.NewPlugin()
+			generatedClusters, _, _, _, err := p.GeneratedResources(params, inClusters, nil, inRouteConfigurations, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(generatedClusters[0].GetConnectionPoolPerDownstreamConnection()).To(BeTrue())
+			Expect(inClusters[0].GetConnectionPoolPerDownstreamConnection()).To(BeFalse())
+		})
+
+		It("should error on an invalid annotation value", func() {
+			us.Metadata.Annotations = map[string]string{tunneling.ConnectionPoolPerDownstreamAnnotation: "per-downstream"}
+
+			p := tunneling.NewPlugin(tunneling.WithStrictMode(true))
+			_, _, _, _, err := p.GeneratedResources(params, inClusters, nil, inRouteConfigurations, nil)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring(tunneling.ConnectionPoolPerDownstreamAnnotation))
+		})
+	})
+
+	Context("CONNECT hostname prefix", func() {
+
+		DescribeTable("should strip the configured prefix from the CONNECT hostname",
+			func(hostname, expectedHostname string) {
+				us.HttpProxyHostname = &wrappers.StringValue{Value: hostname}
+
+				p := tunneling.NewPlugin(tunneling.WithHostnamePrefixStripped("http://"))
+				_, _, _, generatedListeners, err := p.GeneratedResources(params, inClusters, nil, inRouteConfigurations, nil)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(getTcpProxy(generatedListeners[0]).GetTunnelingConfig().GetHostname()).To(Equal(expectedHostname))
+			},
+			Entry("prefix present", "http://proxy.corp.com:3128", "proxy.corp.com:3128"),
+			Entry("prefix present with a different case", "HTTP://Proxy.Corp.com:3128", "proxy.corp.com:3128"),
+			Entry("prefix absent", "proxy.corp.com:3128", "proxy.corp.com:3128"),
+			Entry("shorter than the prefix", "a.io:1", "a.io:1"),
+		)
+
+		It("should not strip anything by default", func() {
+			us.HttpProxyHostname = &wrappers.StringValue{Value: "http://proxy.corp.com:3128"}
+
+			p := tunneling.NewPlugin(tunneling.WithStrictMode(true))
+			_, _, _, _, err := p.GeneratedResources(params, inClusters, nil, inRouteConfigurations, nil)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("invalid CONNECT hostname %q", "http://proxy.corp.com:3128"))
+		})
+
+		It("should validate the stripped hostname", func() {
+			us.HttpProxyHostname = &wrappers.StringValue{Value: "http://:3128"}
+
+			p := tunneling.NewPlugin(tunneling.WithHostnamePrefixStripped("http://"), tunneling.WithStrictMode(true))
+			_, _, _, _, err := p.GeneratedResources(params, inClusters, nil, inRouteConfigurations, nil)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("invalid CONNECT hostname %q", ":3128"))
+			Expect(err.Error()).To(ContainSubstring("missing host"))
+		})
+	})
+
+	Context("unreferenced tunneling upstreams", func() {
+
+		It("should not report unreferenced tunneling upstreams by default", func() {
+			inRouteConfigurations = nil
+
+			p := tunneling.NewPlugin()
+			_, _, _, _, err := p.GeneratedResources(params, inClusters, nil, inRouteConfigurations, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(messages).To(BeEmpty())
+		})
+
+		It("should report tunneling upstreams when there are no route configurations", func() {
+			inRouteConfigurations = nil
+
+			p := tunneling.NewPlugin(tunneling.WithUnreferencedUpstreamWarnings(true))
+			generatedClusters, _, _, generatedListeners, err := p.GeneratedResources(params, inClusters, nil, inRouteConfigurations, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(generatedClusters).To(BeEmpty())
+			Expect(generatedListeners).To(BeEmpty())
+			Expect(messagesFor(us.GetMetadata().Ref())).To(ConsistOf(tunneling.UnreferencedTunnelingUpstreamMessage(us)))
+			Expect(tunneling.UnreferencedTunnelingUpstreamMessage(us)).To(ContainSubstring("tunneling upstream %s is not referenced by any route", us.GetMetadata().Ref().Key()))
+		})
+
+		It("should only report the tunneling upstreams which no route references", func() {
+			unreferencedUs := &v1.Upstream{}
+			us.DeepCopyInto(unreferencedUs)
+			unreferencedUs.Metadata.Name = "unreferenced-http-proxy-upstream"
+			plainUs := &v1.Upstream{
+				Metadata: &core.Metadata{Name: "plain-upstream", Namespace: "gloo-system"},
+			}
+			params.Snapshot.Upstreams = append(params.Snapshot.Upstreams, unreferencedUs, plainUs)
+
+			p := tunneling.NewPlugin(tunneling.WithUnreferencedUpstreamWarnings(true))
+			generatedClusters, _, _, _, err := p.GeneratedResources(params, inClusters, nil, inRouteConfigurations, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(generatedClusters).To(HaveLen(1))
+			Expect(messages).To(HaveLen(1))
+			Expect(messagesFor(unreferencedUs.GetMetadata().Ref())).To(ConsistOf(tunneling.UnreferencedTunnelingUpstreamMessage(unreferencedUs)))
+		})
+	})
+
+	Context("tunneling toggled between snapshots", func() {
+
+		// the translator builds new input resources for every snapshot, the generated resources of the previous
+		// snapshot must not leak into the next one
+		cloneInputs := func() ([]*envoy_config_cluster_v3.Cluster, []*envoy_config_route_v3.RouteConfiguration) {
+			var clusters []*envoy_config_cluster_v3.Cluster
+			for _, c := range inClusters {
+				clusters = append(clusters, proto.Clone(c).(*envoy_config_cluster_v3.Cluster))
+			}
+			var routeConfigs []*envoy_config_route_v3.RouteConfiguration
+			for _, rc := range inRouteConfigurations {
+				routeConfigs = append(routeConfigs, proto.Clone(rc).(*envoy_config_route_v3.RouteConfiguration))
+			}
+			return clusters, routeConfigs
+		}
+
+		BeforeEach(func() {
+			cfg, err := utils.MessageToAny(&envoyauth.UpstreamTlsContext{
+				CommonTlsContext: &envoyauth.CommonTlsContext{},
+				Sni:              "origin.com",
+			})
+			Expect(err).ToNot(HaveOccurred())
+			inClusters[0].TransportSocket = &envoy_config_core_v3.TransportSocket{
+				Name: "",
+				ConfigType: &envoy_config_core_v3.TransportSocket_TypedConfig{
+					TypedConfig: cfg,
+				},
+			}
+		})
+
+		It("should clean up all tunneling resources once tunneling is disabled", func() {
+			p := tunneling.NewPlugin()
+
+			enabledClusters, enabledRouteConfigs := cloneInputs()
+			generatedClusters, _, _, generatedListeners, err := p.GeneratedResources(params, enabledClusters, nil, enabledRouteConfigs, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(generatedClusters).To(HaveLen(1))
+			Expect(generatedListeners).To(HaveLen(1))
+			Expect(enabledRouteConfigs[0].GetVirtualHosts()[0].GetRoutes()[0].GetRoute().GetCluster()).To(Equal(generatedClusters[0].GetName()))
+
+			// disable tunneling on the upstream
+			us.HttpProxyHostname = nil
+
+			disabledClusters, disabledRouteConfigs := cloneInputs()
+			generatedClusters, generatedEndpoints, generatedRouteConfigs, generatedListeners, err := p.GeneratedResources(params, disabledClusters, nil, disabledRouteConfigs, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(generatedClusters).To(BeEmpty())
+			Expect(generatedEndpoints).To(BeEmpty())
+			Expect(generatedRouteConfigs).To(BeEmpty())
+			Expect(generatedListeners).To(BeEmpty())
+
+			// the original routing is left untouched
+			Expect(disabledRouteConfigs).To(HaveLen(len(inRouteConfigurations)))
+			for i := range disabledRouteConfigs {
+				Expect(disabledRouteConfigs[i]).To(matchers.MatchProto(inRouteConfigurations[i]))
+			}
+			for i := range disabledClusters {
+				Expect(disabledClusters[i]).To(matchers.MatchProto(inClusters[i]))
+			}
+		})
+	})
+
+	Context("experimental config", func() {
+
+		It("should apply the tunnel defaults to upstreams which do not override them", func() {
+			otherUs, _, _ := addUpstream("other", "gloo-system", map[string]string{tunneling.TenantAnnotation: "tenant-b"})
+
+			p := tunneling.NewPlugin(tunneling.WithExperimentalConfig(tunneling.ExperimentalConfig{
+				Tenant:                 "tenant-a",
+				AccessLogFlushInterval: &duration.Duration{Seconds: 30},
+			}))
+			_, _, _, generatedListeners, err := p.GeneratedResources(params, inClusters, nil, inRouteConfigurations, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(generatedListeners).To(HaveLen(2))
+
+			Expect(generatedListeners[0].GetAddress().GetPipe().GetPath()).To(Equal("@/tenant-a/" + inClusters[0].GetName()))
+			Expect(getTcpProxy(generatedListeners[0]).GetAccessLogFlushInterval()).To(matchers.MatchProto(&duration.Duration{Seconds: 30}))
+
+			// the annotations of the upstream override the defaults they set
+			Expect(generatedListeners[1].GetAddress().GetPipe().GetPath()).To(Equal("@/tenant-b/" + translator.UpstreamToClusterName(otherUs.GetMetadata().Ref())))
+			Expect(getTcpProxy(generatedListeners[1]).GetAccessLogFlushInterval()).To(matchers.MatchProto(&duration.Duration{Seconds: 30}))
+		})
+
+		It("should apply the annotations of the settings over the tunnel defaults", func() {
+			p := tunneling.NewPlugin(tunneling.WithExperimentalConfig(tunneling.ExperimentalConfig{
+				Tenant:                 "tenant-a",
+				AccessLogFlushInterval: &duration.Duration{Seconds: 30},
+			}))
+			p.Init(plugins.InitParams{
+				Settings: &v1.Settings{
+					Metadata: &core.Metadata{
+						Name:        "default",
+						Namespace:   "gloo-system",
+						Annotations: map[string]string{tunneling.TenantAnnotation: "tenant-b"},
+					},
+				},
+			})
+			_, _, _, generatedListeners, err := p.GeneratedResources(params, inClusters, nil, inRouteConfigurations, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(generatedListeners).To(HaveLen(1))
+			Expect(generatedListeners[0].GetAddress().GetPipe().GetPath()).To(Equal("@/tenant-b/" + inClusters[0].GetName()))
+			Expect(getTcpProxy(generatedListeners[0]).GetAccessLogFlushInterval()).To(matchers.MatchProto(&duration.Duration{Seconds: 30}))
+		})
+
+		It("should error on an invalid tunnel default", func() {
+			p := tunneling.NewPlugin(tunneling.WithExperimentalConfig(tunneling.ExperimentalConfig{
+				StrictMode: true,
+				Tenant:     "Tenant_A",
+			}))
+			_, _, _, _, err := p.GeneratedResources(params, inClusters, nil, inRouteConfigurations, nil)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("invalid default for annotation %s", tunneling.TenantAnnotation))
+		})
+
+		It("should read the behavior of the plugin from the environment", func() {
+			for env, val := range map[string]string{
+				tunneling.StrictModeEnabledEnv:     "TRUE",
+				tunneling.HostnamePrefixToStripEnv: "http://",
+			} {
+				Expect(os.Setenv(env, val)).To(Succeed())
+				defer os.Unsetenv(env)
+			}
+
+			Expect(tunneling.ExperimentalConfigFromEnv()).To(Equal(tunneling.ExperimentalConfig{
+				StrictMode:      true,
+				ConnectHostname: tunneling.ConnectHostnameOptions{PrefixToStrip: "http://"},
+			}))
+		})
+	})
+
+})
+
+type inMemoryExporter struct {
+	lock  sync.Mutex
+	spans []*trace.SpanData
+}
+
+func (e *inMemoryExporter) ExportSpan(s *trace.SpanData) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.spans = append(e.spans, s)
+}
+
+func (e *inMemoryExporter) spansByName() map[string]*trace.SpanData {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	spans := map[string]*trace.SpanData{}
+	for _, s := range e.spans {
+		spans[s.Name] = s
+	}
+	return spans
+}
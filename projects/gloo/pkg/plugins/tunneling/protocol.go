@@ -0,0 +1,122 @@
+package tunneling
+
+import (
+	"net"
+
+	envoy_config_cluster_v3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_extensions_upstreams_http_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/upstreams/http/v3"
+	"github.com/rotisserie/eris"
+	v1 "github.com/solo-io/gloo/projects/gloo/pkg/api/v1"
+	"github.com/solo-io/gloo/projects/gloo/pkg/plugins/pluginutils"
+)
+
+const (
+	alpnH2     = "h2"
+	alpnHttp11 = "http/1.1"
+
+	httpProtocolOptionsExtension = "envoy.extensions.upstreams.http.v3.HttpProtocolOptions"
+)
+
+var (
+	InconsistentHttpConnectProtocolError = func(us *v1.Upstream, reason string) error {
+		return eris.Errorf("inconsistent HTTP CONNECT proxy protocol configuration on tunneling upstream %s: %s", us.GetMetadata().Ref().Key(), reason)
+	}
+)
+
+// validateHttpConnectProtocol checks that envoy can auto-detect the protocol used to reach the HTTP CONNECT proxy,
+// which requires both HTTP/2 and HTTP/1.1 to be offered over ALPN.
+func validateHttpConnectProtocol(us *v1.Upstream, autoDetect bool) error {
+	if !autoDetect {
+		return nil
+	}
+	sslConfig := us.GetHttpConnectSslConfig()
+	if sslConfig == nil {
+		return InconsistentHttpConnectProtocolError(us, "protocol auto-detection requires httpConnectSslConfig to negotiate the protocol over ALPN")
+	}
+	if us.GetUseHttp2().GetValue() {
+		return InconsistentHttpConnectProtocolError(us, "protocol auto-detection cannot be combined with useHttp2")
+	}
+	if offersH2, offersHttp11 := offeredAlpnProtocols(sslConfig); !(offersH2 && offersHttp11) {
+		return InconsistentHttpConnectProtocolError(us, "protocol auto-detection requires both the h2 and http/1.1 ALPN protocols in httpConnectSslConfig.alpnProtocols")
+	}
+	return nil
+}
+
+// httpConnectProtocolInconsistencies lists where the protocol used to reach the HTTP CONNECT proxy disagrees with the
+// TLS configuration of the connection to it: the protocol negotiated over ALPN must be the one envoy speaks, and the
+// SNI must be a host name.
+// envoy accepts such upstreams, which may still work depending on the proxy, so these are only reported as warnings
+// unless strict mode is enabled.
+func httpConnectProtocolInconsistencies(us *v1.Upstream, autoDetect bool) []error {
+	sslConfig := us.GetHttpConnectSslConfig()
+	if sslConfig == nil {
+		return nil
+	}
+
+	var inconsistencies []error
+	if !autoDetect {
+		useHttp2 := us.GetUseHttp2().GetValue()
+		offersH2, _ := offeredAlpnProtocols(sslConfig)
+		switch {
+		case useHttp2 && !offersH2:
+			inconsistencies = append(inconsistencies, InconsistentHttpConnectProtocolError(us, "useHttp2 requires the h2 ALPN protocol in httpConnectSslConfig.alpnProtocols"))
+		case !useHttp2 && offersH2:
+			inconsistencies = append(inconsistencies, InconsistentHttpConnectProtocolError(us, "the h2 ALPN protocol in httpConnectSslConfig.alpnProtocols requires useHttp2"))
+		}
+	}
+
+	if sni := sslConfig.GetSni(); sni != "" {
+		if _, _, err := net.SplitHostPort(sni); err == nil {
+			inconsistencies = append(inconsistencies, InconsistentHttpConnectProtocolError(us, "httpConnectSslConfig.sni must not contain a port"))
+		} else if net.ParseIP(sni) != nil {
+			inconsistencies = append(inconsistencies, InconsistentHttpConnectProtocolError(us, "httpConnectSslConfig.sni must be a host name, not an IP address"))
+		}
+	}
+
+	return inconsistencies
+}
+
+func offeredAlpnProtocols(sslConfig *v1.UpstreamSslConfig) (offersH2, offersHttp11 bool) {
+	for _, protocol := range sslConfig.GetAlpnProtocols() {
+		switch protocol {
+		case alpnH2:
+			offersH2 = true
+		case alpnHttp11:
+			offersHttp11 = true
+		}
+	}
+	return offersH2, offersHttp11
+}
+
+// lets envoy pick the protocol used on the connections of the cluster based on the one negotiated over ALPN.
+// envoy rejects clusters which mix the typed protocol options with the deprecated cluster fields, e.g. set from the
+// connection config of the upstream, so those are moved into the typed options.
+func enableHttpProtocolAutoDetection(cluster *envoy_config_cluster_v3.Cluster) error {
+	http1ProtocolOptions := cluster.GetHttpProtocolOptions()
+	if http1ProtocolOptions == nil {
+		http1ProtocolOptions = &envoy_config_core_v3.Http1ProtocolOptions{}
+	}
+	http2ProtocolOptions := cluster.GetHttp2ProtocolOptions()
+	if http2ProtocolOptions == nil {
+		http2ProtocolOptions = &envoy_config_core_v3.Http2ProtocolOptions{}
+	}
+	err := pluginutils.SetExtensionProtocolOptions(cluster, httpProtocolOptionsExtension, &envoy_extensions_upstreams_http_v3.HttpProtocolOptions{
+		CommonHttpProtocolOptions:   cluster.GetCommonHttpProtocolOptions(),
+		UpstreamHttpProtocolOptions: cluster.GetUpstreamHttpProtocolOptions(),
+		UpstreamProtocolOptions: &envoy_extensions_upstreams_http_v3.HttpProtocolOptions_AutoConfig{
+			AutoConfig: &envoy_extensions_upstreams_http_v3.HttpProtocolOptions_AutoHttpConfig{
+				HttpProtocolOptions:  http1ProtocolOptions,
+				Http2ProtocolOptions: http2ProtocolOptions,
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	cluster.CommonHttpProtocolOptions = nil
+	cluster.UpstreamHttpProtocolOptions = nil
+	cluster.HttpProtocolOptions = nil
+	cluster.Http2ProtocolOptions = nil
+	return nil
+}
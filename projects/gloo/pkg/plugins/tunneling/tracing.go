@@ -0,0 +1,26 @@
+package tunneling
+
+import (
+	"context"
+
+	"go.opencensus.io/trace"
+)
+
+const (
+	GenerateSpanName         = "gloo.tunneling.GenerateTunnelingResources"
+	ScanSpanName             = "gloo.tunneling.ScanRoutes"
+	ResolveTlsSpanName       = "gloo.tunneling.ResolveTls"
+	UpdateTlsContextSpanName = "gloo.tunneling.UpdateUpstreamTlsContext"
+	MarshalSpanName          = "gloo.tunneling.Marshal"
+)
+
+// starts a span if tracing is enabled. the returned span may be nil, which is safe to end
+func (p *plugin) startSpan(ctx context.Context, name string) (context.Context, *trace.Span) {
+	if !p.experimental.Tracing {
+		return ctx, nil
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return trace.StartSpan(ctx, name)
+}
@@ -32,16 +32,18 @@ type GlooValidator interface {
 }
 
 // NewGlooValidator will create a new GlooValidator
-func NewGlooValidator(glooTranslator gloo_translator.Translator, xdsSanitizer sanitizer.XdsSanitizer) GlooValidator {
+func NewGlooValidator(glooTranslator gloo_translator.Translator, xdsSanitizer sanitizer.XdsSanitizer, pluginRegistry plugins.PluginRegistry) GlooValidator {
 	return glooValidator{
 		glooTranslator: glooTranslator,
 		xdsSanitizer:   xdsSanitizer,
+		pluginRegistry: pluginRegistry,
 	}
 }
 
 type glooValidator struct {
 	glooTranslator gloo_translator.Translator
 	xdsSanitizer   sanitizer.XdsSanitizer
+	pluginRegistry plugins.PluginRegistry
 }
 
 type GlooValidationReport struct {
@@ -81,6 +83,13 @@ func (gv glooValidator) Validate(ctx context.Context, proxy *gloov1.Proxy, snaps
 	for _, proxy := range proxiesToValidate {
 		xdsSnapshot, resourceReports, proxyReport := gv.glooTranslator.Translate(params, proxy)
 
+		// report generated resources which envoy would reject, before they are ever sent to it
+		for _, plugin := range gv.pluginRegistry.GetGeneratedResourceValidatorPlugins() {
+			if err := plugin.ValidateGeneratedResources(xdsSnapshot); err != nil {
+				resourceReports.AddError(proxy, err)
+			}
+		}
+
 		// Sanitize routes before sending report to gateway
 		gv.xdsSanitizer.SanitizeSnapshot(ctx, snapshot, xdsSnapshot, resourceReports)
 		routeErrorToWarnings(resourceReports, proxyReport)
@@ -11,6 +11,7 @@ import (
 	"github.com/solo-io/gloo/projects/gloo/pkg/api/grpc/validation"
 	v1 "github.com/solo-io/gloo/projects/gloo/pkg/api/v1"
 	v1snap "github.com/solo-io/gloo/projects/gloo/pkg/api/v1/gloosnapshot"
+	"github.com/solo-io/gloo/projects/gloo/pkg/plugins"
 	"github.com/solo-io/gloo/projects/gloo/pkg/syncer/sanitizer"
 	"github.com/solo-io/gloo/projects/gloo/pkg/translator"
 	"github.com/solo-io/gloo/projects/gloo/pkg/utils"
@@ -42,10 +43,10 @@ type validator struct {
 	xdsSanitizer   sanitizer.XdsSanitizers
 }
 
-func NewValidator(ctx context.Context, translator translator.Translator, xdsSanitizer sanitizer.XdsSanitizers) *validator {
+func NewValidator(ctx context.Context, translator translator.Translator, xdsSanitizer sanitizer.XdsSanitizers, pluginRegistry plugins.PluginRegistry) *validator {
 	return &validator{
 		translator:    translator,
-		glooValidator: NewGlooValidator(translator, xdsSanitizer),
+		glooValidator: NewGlooValidator(translator, xdsSanitizer, pluginRegistry),
 		notifyResync:  make(map[*validation.NotifyOnResyncRequest]chan struct{}, 1),
 		ctx:           ctx,
 		xdsSanitizer:  xdsSanitizer,
@@ -44,6 +44,7 @@ var _ = Describe("Validation Server", func() {
 		translator        Translator
 		params            plugins.Params
 		registeredPlugins []plugins.Plugin
+		pluginRegistry    plugins.PluginRegistry
 		xdsSanitizer      sanitizer.XdsSanitizers
 	)
 
@@ -78,7 +79,7 @@ var _ = Describe("Validation Server", func() {
 	})
 
 	JustBeforeEach(func() {
-		pluginRegistry := registry.NewPluginRegistry(registeredPlugins)
+		pluginRegistry = registry.NewPluginRegistry(registeredPlugins)
 
 		translator = NewTranslatorWithHasher(utils.NewSslConfigTranslator(), settings, pluginRegistry, EnvoyCacheResourcesListToFnvHash)
 	})
@@ -89,7 +90,7 @@ var _ = Describe("Validation Server", func() {
 		Context("validates the requested proxy", func() {
 			It("works with Validate", func() {
 				proxy := params.Snapshot.Proxies[0]
-				s := NewValidator(context.TODO(), translator, xdsSanitizer)
+				s := NewValidator(context.TODO(), translator, xdsSanitizer, pluginRegistry)
 				_ = s.Sync(context.TODO(), params.Snapshot)
 				rpt, err := s.Validate(context.TODO(), &validationgrpc.GlooValidationServiceRequest{Proxy: proxy})
 				Expect(err).NotTo(HaveOccurred())
@@ -105,7 +106,7 @@ var _ = Describe("Validation Server", func() {
 			})
 			It("works with Validate Gloo", func() {
 				proxy := params.Snapshot.Proxies[0]
-				s := NewValidator(context.TODO(), translator, xdsSanitizer)
+				s := NewValidator(context.TODO(), translator, xdsSanitizer, pluginRegistry)
 				_ = s.Sync(context.TODO(), params.Snapshot)
 				rpt, err := s.ValidateGloo(context.TODO(), proxy, nil, false)
 				Expect(err).NotTo(HaveOccurred())
@@ -133,7 +134,7 @@ var _ = Describe("Validation Server", func() {
 				proxy.GetListeners()[0].GetHttpListener().GetVirtualHosts()[0].GetRoutes()[0].Action = errorRouteAction
 				proxy.GetListeners()[2].GetHybridListener().GetMatchedListeners()[0].GetHttpListener().GetVirtualHosts()[0].GetRoutes()[0].Action = errorRouteAction
 
-				s = NewValidator(context.TODO(), translator, xdsSanitizer)
+				s = NewValidator(context.TODO(), translator, xdsSanitizer, pluginRegistry)
 				_ = s.Sync(context.TODO(), params.Snapshot)
 			})
 
@@ -177,7 +178,7 @@ var _ = Describe("Validation Server", func() {
 				}
 				params.Snapshot.Proxies = v1.ProxyList{proxy1, proxy2}
 
-				s = NewValidator(context.TODO(), translator, xdsSanitizer)
+				s = NewValidator(context.TODO(), translator, xdsSanitizer, pluginRegistry)
 				_ = s.Sync(context.TODO(), params.Snapshot)
 			})
 
@@ -230,7 +231,7 @@ var _ = Describe("Validation Server", func() {
 
 			JustBeforeEach(func() {
 				params.Snapshot.Upstreams = v1.UpstreamList{}
-				s = NewValidator(context.TODO(), translator, xdsSanitizer)
+				s = NewValidator(context.TODO(), translator, xdsSanitizer, pluginRegistry)
 				_ = s.Sync(context.TODO(), params.Snapshot)
 				upstream = v1.Upstream{
 					Metadata: &core.Metadata{Name: "other-upstream", Namespace: "other-namespace"},
@@ -270,7 +271,7 @@ var _ = Describe("Validation Server", func() {
 				upstream = v1.Upstream{
 					Metadata: &core.Metadata{Name: "unused-upstream", Namespace: "gloo-system"},
 				}
-				s = NewValidator(context.TODO(), translator, xdsSanitizer)
+				s = NewValidator(context.TODO(), translator, xdsSanitizer, pluginRegistry)
 			})
 
 			validateProxyReport := func(proxyReport *validationgrpc.ProxyReport) {
@@ -312,7 +313,7 @@ var _ = Describe("Validation Server", func() {
 				upstream = v1.Upstream{
 					Metadata: &core.Metadata{Name: "test", Namespace: "gloo-system"},
 				}
-				s = NewValidator(context.TODO(), translator, xdsSanitizer)
+				s = NewValidator(context.TODO(), translator, xdsSanitizer, pluginRegistry)
 				_ = s.Sync(context.TODO(), params.Snapshot)
 			})
 
@@ -350,7 +351,7 @@ var _ = Describe("Validation Server", func() {
 			var secret v1.Secret
 
 			JustBeforeEach(func() {
-				s = NewValidator(context.TODO(), translator, xdsSanitizer)
+				s = NewValidator(context.TODO(), translator, xdsSanitizer, pluginRegistry)
 				_ = s.Sync(context.TODO(), params.Snapshot)
 				secret = v1.Secret{
 					Metadata: &core.Metadata{Name: "unused-secret", Namespace: "gloo-system"},
@@ -392,7 +393,7 @@ var _ = Describe("Validation Server", func() {
 			var secret v1.Secret
 
 			JustBeforeEach(func() {
-				s = NewValidator(context.TODO(), translator, xdsSanitizer)
+				s = NewValidator(context.TODO(), translator, xdsSanitizer, pluginRegistry)
 				_ = s.Sync(context.TODO(), params.Snapshot)
 				secret = v1.Secret{
 					Metadata: &core.Metadata{Name: "secret", Namespace: "gloo-system"},
@@ -461,7 +462,7 @@ var _ = Describe("Validation Server", func() {
 
 			srv = grpc.NewServer()
 
-			v = NewValidator(context.TODO(), nil, xdsSanitizer)
+			v = NewValidator(context.TODO(), nil, xdsSanitizer, nil)
 
 			server := NewValidationServer()
 			server.SetValidator(v)